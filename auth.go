@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// parseBearerToken resolves a -bearer value: @file reads the token from a
+// file, env:NAME from an environment variable, anything else is the token
+func parseBearerToken(s string) (string, error) {
+	var token string
+	switch {
+	case strings.HasPrefix(s, "@"):
+		data, err := os.ReadFile(strings.TrimPrefix(s, "@"))
+		if err != nil {
+			return "", err
+		}
+		token = string(data)
+	case strings.HasPrefix(s, "env:"):
+		name := strings.TrimPrefix(s, "env:")
+		token = os.Getenv(name)
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+	default:
+		token = s
+	}
+
+	// files usually end in a newline that is not part of the token
+	token = strings.TrimSpace(token)
+	if token == "" && s != "" {
+		return "", fmt.Errorf("bearer token %q is empty", s)
+	}
+	return token, nil
+}
+
+// parseBasicAuth splits a -u user:password value, prompting on the terminal
+// for the password when only a user is given
+func parseBasicAuth(s string) (string, string, error) {
+	if s == "" {
+		return "", "", nil
+	}
+
+	user, password, found := strings.Cut(s, ":")
+	if found {
+		return user, password, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter password for user '%s': ", user)
+	raw, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", "", err
+	}
+
+	return user, string(raw), nil
+}
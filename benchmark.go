@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// performGetRequestRepeated issues the request n times on the same client so
+// keep-alive connections are reused, and returns the final hop timings of each run
+func performGetRequestRepeated(client *http.Client, urlArg string, opts requestOptions, n int) ([]timmingsCommon, error) {
+	var samples []timmingsCommon
+	var lastErr error
+
+	fmt.Fprintln(out, au.Magenta("Benchmarking URL:"), au.Cyan(displayURL(urlArg)), au.Magenta(fmt.Sprintf("(%d requests)", n)))
+
+	// which random User-Agent each request sent, failed ones included, so
+	// a run can be reproduced. Per request output would drown the summary
+	var userAgents []string
+	ran := 0
+	for ; ran < n && opts.context().Err() == nil; ran++ {
+		st := &requestState{out: io.Discard}
+		err := runRequest(client, urlArg, opts, st)
+		timeStats, responses = st.timeStats, st.responses
+		if opts.RandomUserAgent {
+			userAgents = append(userAgents, st.userAgent)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(timeStats.CommonTimmings) > 0 {
+			samples = append(samples, timeStats.CommonTimmings[len(timeStats.CommonTimmings)-1])
+		}
+	}
+
+	timeStats.CommonTimmings = samples
+	if ran < n {
+		fmt.Fprintln(out, au.Yellow(fmt.Sprintf("Interrupted after %d of %d requests", ran, n)))
+	}
+	if len(samples) < ran {
+		fmt.Fprintln(out, au.Red(fmt.Sprintf("%d of %d requests failed, last error:", ran-len(samples), ran)), au.Red(lastErr))
+	}
+	if len(userAgents) > 0 {
+		printUserAgents(userAgents)
+	}
+
+	return samples, lastErr
+}
+
+// printUserAgents lists how often each User-Agent went out, then which one
+// every request sent in the order they were made
+func printUserAgents(order []string) {
+	counts := make(map[string]int)
+	for _, agent := range order {
+		counts[agent]++
+	}
+	agents := make([]string, 0, len(counts))
+	for agent := range counts {
+		agents = append(agents, agent)
+	}
+	sort.Strings(agents)
+
+	fmt.Fprintln(out, au.Green("User agents used:"))
+	for _, agent := range agents {
+		fmt.Fprintln(out, au.Blue(fmt.Sprintf("%5d", counts[agent])), agent)
+	}
+
+	fmt.Fprintln(out, au.Green("User agent per request:"))
+	for i, agent := range order {
+		fmt.Fprintln(out, au.Yellow(fmt.Sprintf("%5s", fmt.Sprintf("#%d", i+1))), agent)
+	}
+}
+
+func printTimingPercentiles(samples []timmingsCommon) {
+	phases := []struct {
+		name  string
+		value func(timmingsCommon) time.Duration
+	}{
+		{"DNS lookup", func(t timmingsCommon) time.Duration { return t.DNSLookupTime }},
+		{"TCP connection", func(t timmingsCommon) time.Duration { return t.TCPConnTime }},
+		{"TLS handshake", func(t timmingsCommon) time.Duration { return t.TLSHandshakeTime }},
+		{"TTFB", func(t timmingsCommon) time.Duration { return t.TTFB }},
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, au.Green(fmt.Sprintf("Timing distribution (%d samples)", len(samples))))
+	fmt.Fprintf(out, "%20s %10s %10s %10s %10s %10s\n", "", "min", "median", "p90", "p95", "max")
+
+	for _, phase := range phases {
+		durations := make([]time.Duration, len(samples))
+		for i, sample := range samples {
+			durations[i] = phase.value(sample)
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+		fmt.Fprintf(out, "%20s %10s %10s %10s %10s %10s\n", au.Yellow(phase.name),
+			formatDuration(durations[0]),
+			formatDuration(percentile(durations, 50)),
+			formatDuration(percentile(durations, 90)),
+			formatDuration(percentile(durations, 95)),
+			formatDuration(durations[len(durations)-1]))
+	}
+	// samples on reused connections carry no DNS, TCP or TLS time
+	printConnectionReuseStats(samples)
+}
+
+// percentile uses the nearest-rank method on an already sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
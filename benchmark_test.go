@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+func TestBenchmarkRandomUserAgentOrder(t *testing.T) {
+	const n = 5
+	var mu sync.Mutex
+	var received []string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("request came in over %s, want HTTP/2", r.Proto)
+		}
+		mu.Lock()
+		received = append(received, r.UserAgent())
+		failed := len(received) == 2
+		mu.Unlock()
+		// resets the stream, the client sees a failed request
+		if failed {
+			panic(http.ErrAbortHandler)
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	stdout, colors := out, au
+	out, au = &buf, aurora.NewAurora(false)
+	defer func() { out, au = stdout, colors }()
+
+	client := createHTTPClient(clientOptions{Timeout: 5 * time.Second, Insecure: true, Network: "tcp"})
+	opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10, RandomUserAgent: true}
+	if _, err := performGetRequestRepeated(client, srv.URL, opts, n); err == nil {
+		t.Error("the aborted request was not reported")
+	}
+
+	if len(received) != n {
+		t.Fatalf("server saw %d requests, want %d", len(received), n)
+	}
+	output := buf.String()
+	_, perRequest, ok := strings.Cut(output, "User agent per request:")
+	if !ok {
+		t.Fatalf("no per request list:\n%s", output)
+	}
+	// every request is listed under its own number, the failed one included
+	for i, agent := range received {
+		if agent == "" {
+			t.Fatalf("request %d went out without a User-Agent", i+1)
+		}
+		if want := fmt.Sprintf("#%d %s", i+1, agent); !strings.Contains(perRequest, want) {
+			t.Errorf("missing %q in:\n%s", want, perRequest)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// readBody reads a response body, stopping after limit bytes when limit is
+// positive. truncated reports whether the body went on past the limit
+func readBody(r io.Reader, limit int64) (body []byte, truncated bool, err error) {
+	if limit <= 0 {
+		body, err = io.ReadAll(r)
+		return body, false, err
+	}
+
+	// one byte past the limit tells a body of exactly limit bytes from a longer one
+	body, err = io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(body)) > limit {
+		return body[:limit], true, err
+	}
+	return body, false, err
+}
+
+// newBodyHash returns the digest for a -hash algorithm name
+func newBodyHash(algo string) (hash.Hash, error) {
+	switch algo {
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash %q, expected md5, sha1 or sha256", algo)
+}
+
+// hashBody returns the hex digest of body, empty when algo is
+func hashBody(algo string, body []byte) string {
+	if algo == "" {
+		return ""
+	}
+	h, err := newBodyHash(algo)
+	if err != nil {
+		return ""
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readRequestBody resolves -data, "@path" reads a file ("@-" reads stdin)
+// and anything else is sent as is
+func readRequestBody(data string) ([]byte, error) {
+	path, isFile := strings.CutPrefix(data, "@")
+	if !isFile {
+		return []byte(data), nil
+	}
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	return body, nil
+}
+
+// redirectRequestOptions applies the method and body rules for following a
+// redirect: 303 switches to GET, 301 and 302 turn POST into GET as clients
+// traditionally do, and 307 and 308 repeat the request including its body
+func redirectRequestOptions(opts requestOptions, status int) requestOptions {
+	switch status {
+	case http.StatusSeeOther:
+		if opts.Method != http.MethodHead {
+			opts.Method = http.MethodGet
+		}
+		opts.Body = nil
+	case http.StatusMovedPermanently, http.StatusFound:
+		if opts.Method == http.MethodPost {
+			opts.Method = http.MethodGet
+			opts.Body = nil
+		}
+	}
+	return opts
+}
+
+// createBodyFile opens the -save-body target, stdout for "-". The returned
+// close is a no-op for stdout
+func createBodyFile(path string) (io.Writer, func() error, error) {
+	if path == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating %s: %w", path, err)
+	}
+	return f, func() error {
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("closing %s: %w", path, err)
+		}
+		return nil
+	}, nil
+}
+
+// wireReader hands out at most limit bytes of a response body, all of it
+// when limit is not positive, and records how much was read and when the
+// reading ended
+type wireReader struct {
+	r         io.Reader
+	limit     int64
+	n         int64
+	truncated bool
+	err       error
+	done      time.Time
+}
+
+func (w *wireReader) Read(p []byte) (int, error) {
+	if w.limit > 0 && w.n >= w.limit {
+		if w.done.IsZero() {
+			// one byte past the limit tells a body of exactly limit bytes from a longer one
+			var probe [1]byte
+			n, _ := io.ReadFull(w.r, probe[:])
+			w.truncated = n > 0
+			w.done = time.Now()
+		}
+		return 0, io.EOF
+	}
+	if w.limit > 0 && int64(len(p)) > w.limit-w.n {
+		p = p[:w.limit-w.n]
+	}
+
+	n, err := w.r.Read(p)
+	w.n += int64(n)
+	if err != nil && w.done.IsZero() {
+		w.done = time.Now()
+		if err != io.EOF {
+			w.err = err
+		}
+	}
+	return n, err
+}
+
+// streamedBody is what reading a response body through its decoder measured
+type streamedBody struct {
+	WireSize     int64
+	Size         int64
+	Truncated    bool
+	TransferTime time.Duration
+	Hash         string
+	// only held when streamBody was asked to keep it
+	Decoded []byte
+	// not fatal, the body is passed on undecoded or cut short
+	DecodeErr error
+	SaveErr   error
+}
+
+// streamBody reads a response body once, decoding it on the fly into save
+// (when not nil) and the -hash digest, so a large body is never held in
+// memory unless keep asks for the decoded bytes. The error is only set when
+// reading from the connection failed
+func streamBody(r io.Reader, encoding string, opts requestOptions, save io.Writer, keep bool) (streamedBody, error) {
+	start := time.Now()
+	wire := &wireReader{r: r, limit: opts.MaxBody}
+
+	sinks := []io.Writer{io.Discard}
+	var saved *errWriter
+	if save != nil {
+		saved = &errWriter{w: save}
+		sinks = append(sinks, saved)
+	}
+	var digest hash.Hash
+	if opts.Hash != "" {
+		if h, err := newBodyHash(opts.Hash); err == nil {
+			digest = h
+			sinks = append(sinks, digest)
+		}
+	}
+	var kept bytes.Buffer
+	if keep {
+		sinks = append(sinks, &kept)
+	}
+
+	var result streamedBody
+	decoder, err := newBodyDecoder(encoding, wire)
+	switch {
+	case err == io.EOF:
+		// an empty body, as every HEAD response has, has nothing to decode
+		decoder = wire
+	case err != nil:
+		// unsupported encodings fail before reading, so this is the whole raw body
+		result.DecodeErr = err
+		decoder = wire
+	}
+
+	result.Size, err = io.Copy(io.MultiWriter(sinks...), decoder)
+	if err != nil && wire.err == nil && (saved == nil || saved.err == nil) {
+		// a cut off compressed stream ends early, that is what -max-body asked for
+		if !wire.truncated {
+			result.DecodeErr = err
+		}
+	}
+	// decoders stop at the end of their stream, count whatever trails it
+	io.Copy(io.Discard, wire)
+
+	if wire.err != nil {
+		return result, wire.err
+	}
+	if saved != nil {
+		result.SaveErr = saved.err
+	}
+	result.WireSize = wire.n
+	result.Truncated = wire.truncated
+	result.TransferTime = wire.done.Sub(start)
+	if digest != nil {
+		result.Hash = hex.EncodeToString(digest.Sum(nil))
+	}
+	if keep {
+		result.Decoded = kept.Bytes()
+	}
+	return result, nil
+}
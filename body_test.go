@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestStreamBodyDecodesIntoSave(t *testing.T) {
+	want := strings.Repeat("headview ", 1000)
+	wire := gzipped(t, want)
+
+	var saved bytes.Buffer
+	body, err := streamBody(bytes.NewReader(wire), "gzip", requestOptions{Hash: "sha256"}, &saved, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.String() != want || string(body.Decoded) != want {
+		t.Errorf("saved %d bytes, kept %d, want %d decoded bytes", saved.Len(), len(body.Decoded), len(want))
+	}
+	if body.WireSize != int64(len(wire)) || body.Size != int64(len(want)) {
+		t.Errorf("sizes wire %d decoded %d, want %d and %d", body.WireSize, body.Size, len(wire), len(want))
+	}
+	if body.Truncated || body.DecodeErr != nil || body.SaveErr != nil {
+		t.Errorf("unexpected truncated=%v decode=%v save=%v", body.Truncated, body.DecodeErr, body.SaveErr)
+	}
+	if body.Hash != hashBody("sha256", []byte(want)) {
+		t.Errorf("hash %s does not match the decoded body", body.Hash)
+	}
+}
+
+func TestStreamBodyMaxBody(t *testing.T) {
+	wire := gzipped(t, strings.Repeat("0123456789", 10000))
+
+	tests := []struct {
+		limit     int64
+		truncated bool
+	}{
+		{int64(len(wire)) / 2, true},
+		{int64(len(wire)), false},
+		{int64(len(wire)) + 1, false},
+	}
+	for _, tt := range tests {
+		var saved bytes.Buffer
+		body, err := streamBody(bytes.NewReader(wire), "gzip", requestOptions{MaxBody: tt.limit}, &saved, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body.Truncated != tt.truncated {
+			t.Errorf("limit %d: truncated %v, want %v", tt.limit, body.Truncated, tt.truncated)
+		}
+		if tt.truncated && body.WireSize != tt.limit {
+			t.Errorf("limit %d: read %d wire bytes", tt.limit, body.WireSize)
+		}
+		// a cut off stream is expected to end early, it is not a decoding error
+		if body.DecodeErr != nil {
+			t.Errorf("limit %d: decode error %v", tt.limit, body.DecodeErr)
+		}
+		if int64(saved.Len()) != body.Size {
+			t.Errorf("limit %d: saved %d bytes, counted %d", tt.limit, saved.Len(), body.Size)
+		}
+	}
+}
+
+func TestStreamBodyEmptyEncoded(t *testing.T) {
+	// HEAD responses announce the encoding of a body they do not send
+	body, err := streamBody(bytes.NewReader(nil), "gzip", requestOptions{}, nil, false)
+	if err != nil || body.DecodeErr != nil || body.Size != 0 {
+		t.Errorf("empty gzip body: err %v, decode %v, size %d", err, body.DecodeErr, body.Size)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// byteRange is a single -range spec. An open end is -1, a suffix range
+// ("-500", the last 500 bytes) has Start -1 and the length in End
+type byteRange struct {
+	Start int64
+	End   int64
+}
+
+func parseByteRange(s string) (byteRange, error) {
+	first, last, found := strings.Cut(strings.TrimPrefix(strings.TrimSpace(s), "bytes="), "-")
+	if !found || (first == "" && last == "") {
+		return byteRange{}, fmt.Errorf("invalid range %q, expected start-end, start- or -suffix", s)
+	}
+
+	r := byteRange{Start: -1, End: -1}
+	var err error
+	if first != "" {
+		if r.Start, err = strconv.ParseInt(first, 10, 64); err != nil || r.Start < 0 {
+			return byteRange{}, fmt.Errorf("invalid range start %q", first)
+		}
+	}
+	if last != "" {
+		if r.End, err = strconv.ParseInt(last, 10, 64); err != nil || r.End < 0 {
+			return byteRange{}, fmt.Errorf("invalid range end %q", last)
+		}
+	}
+	if r.Start >= 0 && r.End >= 0 && r.End < r.Start {
+		return byteRange{}, fmt.Errorf("invalid range %q, end is before start", s)
+	}
+	return r, nil
+}
+
+func (r byteRange) String() string {
+	var first, last string
+	if r.Start >= 0 {
+		first = strconv.FormatInt(r.Start, 10)
+	}
+	if r.End >= 0 {
+		last = strconv.FormatInt(r.End, 10)
+	}
+	return "bytes=" + first + "-" + last
+}
+
+// parseContentRange reads "bytes first-last/total", total is -1 when the
+// server sent * for it
+func parseContentRange(value string) (first, last, total int64, err error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("not a bytes range")
+	}
+	span, size, _ := strings.Cut(spec, "/")
+	from, to, _ := strings.Cut(span, "-")
+	if first, err = strconv.ParseInt(from, 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if last, err = strconv.ParseInt(to, 10, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	return first, last, total, nil
+}
+
+// matchesRange checks a 206 covers what was asked for. Servers may cut the
+// end short at the size of the resource, but never start elsewhere
+func matchesRange(r byteRange, first, last, total int64) bool {
+	if last < first {
+		return false
+	}
+	if r.Start < 0 {
+		return last-first+1 <= r.End && (total < 0 || last == total-1)
+	}
+	return first == r.Start && (r.End < 0 || last <= r.End)
+}
+
+func printRangeSupport(resp *http.Response, requested byteRange) {
+	fmt.Fprintln(out, au.Green("Range check:"))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Requested"), au.Blue(requested.String()))
+	for _, name := range []string{"Content-Range", "Accept-Ranges"} {
+		value := resp.Header.Get(name)
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow(name), au.Blue(value))
+	}
+
+	verdict := au.Green("yes")
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		first, last, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		switch {
+		case err != nil:
+			verdict = au.Red("no (206 without a valid Content-Range)")
+		case !matchesRange(requested, first, last, total):
+			verdict = au.Red(fmt.Sprintf("no (sent bytes %d-%d, not the requested range)", first, last))
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		verdict = au.Yellow("yes, but the range is past the end of the resource")
+	case http.StatusOK:
+		verdict = au.Red("no (ignored the range, sent the full body with 200)")
+		if strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") {
+			verdict = au.Red("no (ignored the range despite Accept-Ranges: bytes)")
+		}
+	default:
+		verdict = au.Yellow(fmt.Sprintf("unknown (status %d)", resp.StatusCode))
+	}
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Range supported"), verdict)
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseCacheControl splits a Cache-Control header into lowercase directives,
+// directives without a value map to ""
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return directives
+}
+
+// freshnessLifetime follows RFC 7234 4.2.1: s-maxage, then max-age, then Expires - Date
+func freshnessLifetime(header http.Header, directives map[string]string) (time.Duration, bool) {
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[name]; ok {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				return time.Duration(seconds) * time.Second, true
+			}
+		}
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			// invalid Expires means already expired
+			return 0, true
+		}
+		date := time.Now()
+		if d, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = d
+		}
+		return expiresAt.Sub(date), true
+	}
+
+	return 0, false
+}
+
+func printCacheAnalysis(resp *http.Response) {
+	header := resp.Header
+	directives := parseCacheControl(header.Get("Cache-Control"))
+
+	fmt.Fprintln(out, au.Green("Cache analysis:"))
+	for _, name := range []string{"Cache-Control", "Expires", "ETag", "Age", "Last-Modified"} {
+		value := header.Get(name)
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow(name), au.Blue(value))
+	}
+
+	var age time.Duration
+	if seconds, err := strconv.Atoi(header.Get("Age")); err == nil {
+		age = time.Duration(seconds) * time.Second
+	}
+	lifetime, hasLifetime := freshnessLifetime(header, directives)
+
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	_, noCache := directives["no-cache"]
+	hasValidator := header.Get("ETag") != "" || header.Get("Last-Modified") != ""
+
+	cacheable := !noStore && (hasLifetime || hasValidator)
+	verdict := au.Green("yes")
+	switch {
+	case !cacheable:
+		verdict = au.Red("no")
+	case private:
+		verdict = au.Yellow("yes (private, browser only)")
+	case noCache:
+		verdict = au.Yellow("yes (must revalidate)")
+	}
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Cacheable"), verdict)
+
+	if cacheable && hasLifetime {
+		remaining := lifetime - age
+		if remaining > 0 {
+			fmt.Fprintf(out, "%20s %s\n", au.Yellow("Fresh for"), au.Green(remaining.String()))
+		} else {
+			fmt.Fprintf(out, "%20s %s\n", au.Yellow("Fresh for"), au.Red("stale"))
+		}
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseStatusPatterns accepts a comma separated list of codes or classes like 4xx
+func parseStatusPatterns(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var patterns []string
+	for _, part := range strings.Split(s, ",") {
+		pattern := strings.ToLower(strings.TrimSpace(part))
+		if len(pattern) != 3 {
+			return nil, fmt.Errorf("invalid status pattern %q", part)
+		}
+		for _, c := range pattern {
+			if (c < '0' || c > '9') && c != 'x' {
+				return nil, fmt.Errorf("invalid status pattern %q", part)
+			}
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func matchStatus(patterns []string, code int) bool {
+	status := strconv.Itoa(code)
+	for _, pattern := range patterns {
+		if len(status) != len(pattern) {
+			continue
+		}
+		matched := true
+		for i := range pattern {
+			if pattern[i] != 'x' && pattern[i] != status[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExpectations prints PASS or FAIL for each -expect-status and
+// -expect-header assertion against the final response, false when any failed
+func checkExpectations(run runOptions) bool {
+	if len(run.ExpectStatus) == 0 && len(run.ExpectHeaders) == 0 {
+		return true
+	}
+	if len(responses) == 0 {
+		fmt.Fprintln(out, au.Red("FAIL"), "no response to check expectations against")
+		return false
+	}
+
+	final := responses[len(responses)-1]
+	passed := true
+	report := func(ok bool, format string, args ...any) {
+		if ok {
+			fmt.Fprintln(out, au.Green("PASS"), fmt.Sprintf(format, args...))
+			return
+		}
+		fmt.Fprintln(out, au.Red("FAIL"), fmt.Sprintf(format, args...))
+		passed = false
+	}
+
+	if len(run.ExpectStatus) > 0 {
+		report(matchStatus(run.ExpectStatus, final.StatusCode), "status %d, expected %s", final.StatusCode, strings.Join(run.ExpectStatus, " or "))
+	}
+
+	for _, exp := range run.ExpectHeaders {
+		values, found := final.Response.Header[http.CanonicalHeaderKey(exp.Name)]
+		switch {
+		case !found:
+			report(false, "header %s missing, expected %s", exp.Name, exp)
+		case exp.Present:
+			report(true, "header %s present", exp.Name)
+		default:
+			matched := false
+			for _, value := range values {
+				if value == exp.Value || (exp.Substring && strings.Contains(value, exp.Value)) {
+					matched = true
+				}
+			}
+			report(matched, "header %s is %q, expected %s", exp.Name, strings.Join(values, ", "), exp)
+		}
+	}
+
+	return passed
+}
+
+// checkThresholds returns the reasons the last run should fail, if any
+func checkThresholds(run runOptions) []string {
+	var reasons []string
+
+	if len(responses) > 0 {
+		final := responses[len(responses)-1]
+		if matchStatus(run.FailOnStatus, final.Response.StatusCode) {
+			reasons = append(reasons, fmt.Sprintf("%s returned status %d", final.URL, final.Response.StatusCode))
+		}
+	}
+
+	if run.MaxTTFB > 0 && len(timeStats.CommonTimmings) > 0 {
+		ttfb := timeStats.CommonTimmings[len(timeStats.CommonTimmings)-1].TTFB
+		if ttfb > run.MaxTTFB {
+			reasons = append(reasons, fmt.Sprintf("TTFB %s exceeds %s", formatDuration(ttfb), run.MaxTTFB))
+		}
+	}
+
+	return reasons
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// enableANSI has nothing to switch on, every other terminal renders ANSI escapes
+func enableANSI(*os.File) bool {
+	return true
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// volatileHeaders change between any two requests and would only add noise to a diff
+var volatileHeaders = map[string]bool{
+	"Date": true,
+	"Age":  true,
+}
+
+// compareMethods requests each target with HEAD and then GET and reports how
+// the two responses differ. Returns 1 when a target could not be requested
+func compareMethods(client *http.Client, targets []string, opts requestOptions) int {
+	exitCode := 0
+	for _, target := range targets {
+		fmt.Fprintln(out, au.Magenta("Comparing HEAD and GET for:"), au.Cyan(displayURL(target)))
+
+		head, headErr := requestFinal(client, target, opts, http.MethodHead)
+		get, getErr := requestFinal(client, target, opts, http.MethodGet)
+		if headErr != nil || getErr != nil {
+			fmt.Fprintln(out, au.Red("Error requesting:"), au.Red(firstError(headErr, getErr)))
+			exitCode = 1
+			continue
+		}
+
+		if head.StatusCode != get.StatusCode {
+			fmt.Fprintln(out, au.Yellow("Status differs:"), au.Blue("HEAD "+head.Response.Status), au.Blue("GET "+get.Response.Status))
+		} else {
+			fmt.Fprintln(out, au.Green("Status:"), colorizeByStatus(head.StatusCode, head.Response.Status))
+		}
+
+		printHeaderDiff("HEAD", head.Response.Header, "GET", get.Response.Header)
+		printSizeComparison(head, get)
+		fmt.Fprintln(out)
+	}
+	return exitCode
+}
+
+// requestFinal runs the request pipeline quietly and returns the final response
+func requestFinal(client *http.Client, target string, opts requestOptions, method string) (responseInfo, error) {
+	timeStats = timmings{}
+	responses = nil
+	opts.Method = method
+
+	stdout := out
+	out = io.Discard
+	err := performGetRequest(client, target, opts)
+	out = stdout
+
+	if len(responses) == 0 {
+		if err == nil {
+			err = fmt.Errorf("%s %s returned no response", method, target)
+		}
+		return responseInfo{}, err
+	}
+	return responses[len(responses)-1], nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printHeaderDiff lists headers only one side sent and headers whose values
+// differ, skipping volatileHeaders
+func printHeaderDiff(nameA string, a http.Header, nameB string, b http.Header) {
+	keys := make(map[string]bool)
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		if !volatileHeaders[key] {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+
+	var differences int
+	for _, key := range sorted {
+		valueA, inA := a[key]
+		valueB, inB := b[key]
+		switch {
+		case !inA:
+			fmt.Fprintln(out, au.Yellow("  only "+nameB+":"), au.Green(key+":"), au.Blue(strings.Join(valueB, ", ")))
+		case !inB:
+			fmt.Fprintln(out, au.Yellow("  only "+nameA+":"), au.Green(key+":"), au.Blue(strings.Join(valueA, ", ")))
+		case strings.Join(valueA, ", ") != strings.Join(valueB, ", "):
+			fmt.Fprintln(out, au.Yellow("  differs:"), au.Green(key+":"))
+			fmt.Fprintln(out, "    ", au.Yellow(nameA), au.Blue(strings.Join(valueA, ", ")))
+			fmt.Fprintln(out, "    ", au.Yellow(nameB), au.Blue(strings.Join(valueB, ", ")))
+		default:
+			continue
+		}
+		differences++
+	}
+
+	if differences == 0 {
+		fmt.Fprintln(out, au.Green("Headers match"))
+	}
+}
+
+// printSizeComparison checks the Content-Length HEAD announced against what
+// GET announced and actually transferred
+func printSizeComparison(head, get responseInfo) {
+	headLength := head.Response.Header.Get("Content-Length")
+	getLength := get.Response.Header.Get("Content-Length")
+
+	if headLength == "" {
+		fmt.Fprintln(out, au.Yellow("HEAD sent no Content-Length"))
+	} else if length, err := strconv.ParseInt(headLength, 10, 64); err == nil && length != get.WireSize {
+		fmt.Fprintln(out, au.Yellow("Size differs:"), au.Blue("HEAD Content-Length "+headLength), au.Blue(fmt.Sprintf("GET transferred %d", get.WireSize)))
+	} else {
+		fmt.Fprintln(out, au.Green("Size:"), au.Blue(get.WireSize), au.Green("bytes on both"))
+	}
+
+	if getLength == "" && headLength != "" {
+		fmt.Fprintln(out, au.Yellow("GET sent no Content-Length"))
+	}
+}
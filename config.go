@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config holds defaults read from ~/.headview.yaml or -config. Precedence is
+// flags > HEADVIEW_* environment variables > config file > built-in defaults
+type config struct {
+	Timeout   time.Duration     `yaml:"timeout"`
+	UserAgent string            `yaml:"user_agent"`
+	Headers   map[string]string `yaml:"headers"`
+	Insecure  bool              `yaml:"insecure"`
+	// the -concurrency default, resources or sitemap pages fetched at once
+	Concurrency int `yaml:"concurrency"`
+}
+
+// defaultConfigPath is ~/.headview.yaml, empty when there is no home directory
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".headview.yaml")
+}
+
+// configPathFromArgs finds -config before the flag set is parsed, since the
+// file supplies the defaults the flags are declared with
+func configPathFromArgs(args []string) (string, bool) {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value, true
+		}
+		if i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return defaultConfigPath(), false
+}
+
+// loadConfig reads the config file and applies HEADVIEW_* overrides. A missing
+// default file is not an error, a missing -config file is
+func loadConfig(path string, explicit bool) (config, error) {
+	var cfg config
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(data, &cfg); err != nil {
+				return cfg, fmt.Errorf("parsing %s: %w", path, err)
+			}
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		default:
+			return cfg, fmt.Errorf("reading config: %w", err)
+		}
+	}
+
+	if v := os.Getenv("HEADVIEW_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("HEADVIEW_TIMEOUT: %w", err)
+		}
+		cfg.Timeout = timeout
+	}
+	if v := os.Getenv("HEADVIEW_USER_AGENT"); v != "" {
+		cfg.UserAgent = v
+	}
+	if v := os.Getenv("HEADVIEW_INSECURE"); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("HEADVIEW_INSECURE: %w", err)
+		}
+		cfg.Insecure = insecure
+	}
+	if v := os.Getenv("HEADVIEW_CONCURRENCY"); v != "" {
+		concurrency, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("HEADVIEW_CONCURRENCY: %w", err)
+		}
+		cfg.Concurrency = concurrency
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigConcurrency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headview.yaml")
+	if err := os.WriteFile(path, []byte("concurrency: 12\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HEADVIEW_CONCURRENCY", "")
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Concurrency != 12 {
+		t.Errorf("concurrency %d from the file, want 12", cfg.Concurrency)
+	}
+
+	t.Setenv("HEADVIEW_CONCURRENCY", "3")
+	if cfg, err = loadConfig(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Concurrency != 3 {
+		t.Errorf("concurrency %d with HEADVIEW_CONCURRENCY=3, want 3", cfg.Concurrency)
+	}
+
+	t.Setenv("HEADVIEW_CONCURRENCY", "many")
+	if _, err = loadConfig(path, true); err == nil {
+		t.Error("HEADVIEW_CONCURRENCY=many was accepted")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// writeResourceCSV writes one url,type,size_bytes row per resource, sorted by
+// type, between a header row and a total row
+func writeResourceCSV(w io.Writer, resMap resourceMap) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"url", "type", "size_bytes"}); err != nil {
+		return err
+	}
+
+	types := make([]string, 0, len(resMap))
+	for resType := range resMap {
+		types = append(types, resType)
+	}
+	sort.Strings(types)
+
+	var total int64
+	for _, resType := range types {
+		for _, resource := range resMap[resType] {
+			if err := cw.Write([]string{resource.URL, resType, strconv.FormatInt(resource.Size, 10)}); err != nil {
+				return err
+			}
+			total += resource.Size
+		}
+	}
+
+	if err := cw.Write([]string{"total", "", strconv.FormatInt(total, 10)}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// diffTargets requests target and other through the same pipeline and reports
+// how the two final responses differ. Returns 1 when either request failed
+func diffTargets(client *http.Client, target, other string, opts requestOptions) int {
+	fmt.Fprintln(out, au.Magenta("Comparing"))
+	fmt.Fprintln(out, au.Yellow("  A:"), au.Cyan(displayURL(target)))
+	fmt.Fprintln(out, au.Yellow("  B:"), au.Cyan(displayURL(other)))
+
+	a, errA := requestFinal(client, target, opts, opts.Method)
+	statsA := timeStats
+	b, errB := requestFinal(client, other, opts, opts.Method)
+	statsB := timeStats
+	if errA != nil || errB != nil {
+		fmt.Fprintln(out, au.Red("Error requesting:"), au.Red(firstError(errA, errB)))
+		return 1
+	}
+
+	// the last connection is the one the final response came over
+	var connA, connB timmingsCommon
+	if n := len(statsA.CommonTimmings); n > 0 {
+		connA = statsA.CommonTimmings[n-1]
+	}
+	if n := len(statsB.CommonTimmings); n > 0 {
+		connB = statsB.CommonTimmings[n-1]
+	}
+	printDiff(a, b, connA, connB)
+
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Total request"), timingDelta(statsA.TotalRequestTime, statsB.TotalRequestTime))
+	fmt.Fprintln(out)
+	return 0
+}
+
+// printDiff lays the status, headers, TLS details and connection timings of
+// two responses against each other. Timing deltas read as B relative to A
+func printDiff(a, b responseInfo, ta, tb timmingsCommon) {
+	if a.StatusCode != b.StatusCode {
+		fmt.Fprintln(out, au.Yellow("Status differs:"), au.Blue("A "+a.Response.Status), au.Blue("B "+b.Response.Status))
+	} else {
+		fmt.Fprintln(out, au.Green("Status:"), colorizeByStatus(a.StatusCode, a.Response.Status))
+	}
+
+	fmt.Fprintln(out, au.Green("Headers"))
+	printHeaderDiff("A", a.Response.Header, "B", b.Response.Header)
+
+	fmt.Fprintln(out, au.Green("TLS"))
+	details := []struct {
+		name string
+		a, b string
+	}{
+		{"Protocol", a.Response.Proto, b.Response.Proto},
+		{"TLS version", ta.TLSVersion, tb.TLSVersion},
+		{"Cipher suite", ta.TLSCipherSuite, tb.TLSCipherSuite},
+		{"ALPN", ta.ALPNProtocol, tb.ALPNProtocol},
+		{"Certificate", firstOrEmpty(ta.TLSCertSubjects), firstOrEmpty(tb.TLSCertSubjects)},
+		{"Issuer", firstOrEmpty(ta.TLSCertIssuers), firstOrEmpty(tb.TLSCertIssuers)},
+	}
+	var differences int
+	for _, d := range details {
+		if d.a == d.b {
+			continue
+		}
+		differences++
+		fmt.Fprintln(out, au.Yellow("  differs:"), au.Green(d.name+":"))
+		fmt.Fprintln(out, "    ", au.Yellow("A"), au.Blue(orNone(d.a)))
+		fmt.Fprintln(out, "    ", au.Yellow("B"), au.Blue(orNone(d.b)))
+	}
+	if differences == 0 {
+		fmt.Fprintln(out, au.Green("TLS details match"))
+	}
+
+	fmt.Fprintln(out, au.Green("Timing (B against A)"))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("DNS lookup"), timingDelta(ta.DNSLookupTime, tb.DNSLookupTime))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("TCP connection"), timingDelta(ta.TCPConnTime, tb.TCPConnTime))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("TLS handshake"), timingDelta(ta.TLSHandshakeTime, tb.TLSHandshakeTime))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("TTFB"), timingDelta(ta.TTFB, tb.TTFB))
+}
+
+// timingDelta shows both durations and how much slower or faster b was
+func timingDelta(a, b time.Duration) string {
+	both := fmt.Sprintf("%s -> %s", formatDuration(a), formatDuration(b))
+	switch {
+	case b > a:
+		return both + " " + au.Red(fmt.Sprintf("(+%s slower)", formatDuration(b-a))).String()
+	case b < a:
+		return both + " " + au.Green(fmt.Sprintf("(-%s faster)", formatDuration(a-b))).String()
+	}
+	return both + " " + au.Blue("(same)").String()
+}
+
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
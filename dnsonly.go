@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dnsResult is what -dns-only reports for one host
+type dnsResult struct {
+	Host     string
+	Duration time.Duration
+	IPv4     []net.IP
+	IPv6     []net.IP
+	// the address came from -resolve, no lookup was made
+	Pinned bool
+}
+
+// resolveOnly resolves host the way the HTTP client would: -resolve pins win
+// and network limits the lookup to A (tcp4) or AAAA (tcp6) records
+func resolveOnly(ctx context.Context, host, network string, resolve map[string]string, timeout time.Duration) (dnsResult, error) {
+	result := dnsResult{Host: host}
+
+	if pinned, ok := resolve[strings.ToLower(host)]; ok {
+		result.Pinned = true
+		result.addIP(net.ParseIP(pinned))
+		return result, nil
+	}
+
+	lookupNetwork := "ip"
+	switch network {
+	case "tcp4":
+		lookupNetwork = "ip4"
+	case "tcp6":
+		lookupNetwork = "ip6"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	ips, err := net.DefaultResolver.LookupIP(ctx, lookupNetwork, host)
+	result.Duration = time.Since(start)
+	if err != nil {
+		return result, fmt.Errorf("resolving %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		result.addIP(ip)
+	}
+	return result, nil
+}
+
+func (r *dnsResult) addIP(ip net.IP) {
+	if ip.To4() != nil {
+		r.IPv4 = append(r.IPv4, ip)
+	} else {
+		r.IPv6 = append(r.IPv6, ip)
+	}
+}
+
+// probeDNS resolves the host of every target and returns the exit code
+func probeDNS(ctx context.Context, targets []string, network string, resolve map[string]string, timeout time.Duration) int {
+	exitCode := 0
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return exitInterrupted
+		}
+		u, err := url.Parse(target)
+		if err != nil || u.Hostname() == "" {
+			fmt.Fprintln(out, au.Red("Cannot find a host in:"), au.Red(target))
+			exitCode = 1
+			continue
+		}
+
+		fmt.Fprintln(out, au.Magenta("Resolving:"), au.Cyan(u.Hostname()))
+		result, err := resolveOnly(ctx, u.Hostname(), network, resolve, timeout)
+		if err != nil {
+			fmt.Fprintln(out, au.Red("DNS resolution failed:"), au.Red(err))
+			exitCode = 1
+			continue
+		}
+		printDNSResult(result)
+	}
+	return exitCode
+}
+
+func printDNSResult(r dnsResult) {
+	if r.Pinned {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Lookup"), au.Blue("skipped, pinned by -resolve"))
+	} else {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Lookup"), au.Blue(formatDuration(r.Duration)))
+	}
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("A"), au.Blue(joinIPs(r.IPv4)))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("AAAA"), au.Blue(joinIPs(r.IPv6)))
+	fmt.Fprintln(out)
+}
+
+func joinIPs(ips []net.IP) string {
+	if len(ips) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(ips))
+	for i, ip := range ips {
+		parts[i] = ip.String()
+	}
+	return strings.Join(parts, ", ")
+}
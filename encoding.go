@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// the transport has compression disabled so we can see the wire size,
+// which means we have to ask for and decode compressed bodies ourselves
+const acceptEncoding = "gzip, deflate, br"
+
+func setAcceptEncoding(req *http.Request) {
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+}
+
+// decodeBody returns the decompressed body for the given Content-Encoding
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+
+	r, err := newBodyDecoder(encoding, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// newBodyDecoder wraps r in a reader that decompresses the given
+// Content-Encoding as it is read
+func newBodyDecoder(encoding string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return r, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return newDeflateReader(r)
+	case "br":
+		return brotli.NewReader(r), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
+
+// newDeflateReader reads "deflate" the way browsers do. RFC 9110 means zlib
+// wrapped data, but enough servers send a raw DEFLATE stream that a body
+// without a valid zlib header is read as one
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	// a zlib header names the deflate method and its two bytes are a
+	// multiple of 31, see RFC 1950
+	if header[0]&0x0f != 8 || (uint16(header[0])<<8|uint16(header[1]))%31 != 0 {
+		return flate.NewReader(br), nil
+	}
+	return zlib.NewReader(br)
+}
+
+func printTransferSize(w io.Writer, encoding string, wireSize, size int64) {
+	if encoding == "" || strings.EqualFold(encoding, "identity") {
+		fmt.Fprintln(w, au.Green("Transferred:"), au.Blue(wireSize))
+		return
+	}
+
+	var saved float64
+	if size > 0 {
+		saved = 100 - float64(wireSize)/float64(size)*100
+	}
+	fmt.Fprintln(w, au.Green("Transferred:"), au.Blue(fmt.Sprintf("%d (%s)", wireSize, encoding)),
+		au.Green("/ Decompressed:"), au.Blue(fmt.Sprintf("%d (%.1f%% saved)", size, saved)))
+}
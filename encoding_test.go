@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"testing"
+)
+
+func TestDecodeBodyDeflate(t *testing.T) {
+	const want = "<html><body>deflated</body></html>"
+
+	var wrapped bytes.Buffer
+	zw := zlib.NewWriter(&wrapped)
+	zw.Write([]byte(want))
+	zw.Close()
+
+	var raw bytes.Buffer
+	fw, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	fw.Write([]byte(want))
+	fw.Close()
+
+	for name, body := range map[string][]byte{"zlib": wrapped.Bytes(), "raw": raw.Bytes()} {
+		got, err := decodeBody("deflate", body)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
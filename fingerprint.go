@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var fingerprintHeaders = []string{
+	"Server",
+	"X-Powered-By",
+	"Via",
+	"X-Served-By",
+	"CF-Ray",
+	"X-Amz-Cf-Id",
+	"Fastly-Debug-Digest",
+}
+
+// printServerFingerprint lists the headers that give away the serving stack
+// and a best guess at the edge and origin behind it
+func printServerFingerprint(resp *http.Response) {
+	fmt.Fprintln(out, au.Green("Server fingerprint:"))
+
+	var seen int
+	for _, header := range fingerprintHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			seen++
+			fmt.Fprintf(out, "%22s %s\n", au.Green(header), au.Blue(value))
+		}
+	}
+	if seen == 0 {
+		fmt.Fprintln(out, au.Yellow("No identifying headers sent"))
+		fmt.Fprintln(out)
+		return
+	}
+
+	cdn, origin := guessServerStack(resp.Header)
+	if cdn == "" {
+		cdn = "none detected"
+	}
+	if origin == "" {
+		origin = "unknown"
+	}
+	fmt.Fprintf(out, "%22s %s\n", au.Green("CDN:"), au.Cyan(cdn))
+	fmt.Fprintf(out, "%22s %s\n", au.Green("Origin:"), au.Cyan(origin))
+	fmt.Fprintln(out)
+}
+
+// guessServerStack names the CDN from its tracing headers and builds the
+// origin from whatever Server and X-Powered-By say once the edge's own
+// Server value is set aside
+func guessServerStack(header http.Header) (cdn, origin string) {
+	server := header.Get("Server")
+	via := strings.ToLower(header.Get("Via"))
+
+	switch {
+	case header.Get("CF-Ray") != "" || strings.EqualFold(server, "cloudflare"):
+		cdn = "Cloudflare"
+	case header.Get("X-Amz-Cf-Id") != "" || strings.Contains(via, "cloudfront"):
+		cdn = "Amazon CloudFront"
+	case header.Get("Fastly-Debug-Digest") != "" || strings.HasPrefix(header.Get("X-Served-By"), "cache-"):
+		cdn = "Fastly"
+	case strings.HasPrefix(server, "AkamaiGHost"):
+		cdn = "Akamai"
+	case strings.Contains(via, "varnish"):
+		cdn = "Varnish"
+	}
+
+	var stack []string
+	if server != "" && !isEdgeServer(server) {
+		stack = append(stack, server)
+	}
+	if poweredBy := header.Get("X-Powered-By"); poweredBy != "" {
+		stack = append(stack, poweredBy)
+	}
+	return cdn, strings.Join(stack, ", ")
+}
+
+// isEdgeServer reports Server values that name the CDN rather than the origin
+func isEdgeServer(server string) bool {
+	lower := strings.ToLower(server)
+	for _, edge := range []string{"cloudflare", "cloudfront", "akamaighost", "varnish"} {
+		if strings.HasPrefix(lower, edge) {
+			return true
+		}
+	}
+	return false
+}
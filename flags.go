@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// headerFlags collects repeated -H "Key: Value" arguments
+type headerFlags struct {
+	header http.Header
+}
+
+func (h *headerFlags) String() string {
+	if h == nil || h.header == nil {
+		return ""
+	}
+
+	var parts []string
+	for key, values := range h.header {
+		for _, value := range values {
+			parts = append(parts, key+": "+value)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *headerFlags) Set(s string) error {
+	key, value, found := strings.Cut(s, ":")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return fmt.Errorf("malformed header %q, expected \"Key: Value\"", s)
+	}
+
+	if h.header == nil {
+		h.header = make(http.Header)
+	}
+	h.header.Add(key, strings.TrimSpace(value))
+	return nil
+}
+
+// resolveFlags collects repeated -resolve host:ip overrides
+type resolveFlags map[string]string
+
+func (r resolveFlags) String() string {
+	var parts []string
+	for host, ip := range r {
+		parts = append(parts, host+":"+ip)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r resolveFlags) Set(s string) error {
+	host, ip, found := strings.Cut(s, ":")
+	if !found || host == "" || net.ParseIP(ip) == nil {
+		return fmt.Errorf("malformed resolve entry %q, expected host:ip", s)
+	}
+	r[strings.ToLower(host)] = ip
+	return nil
+}
+
+// cookieFlags collects repeated -cookie name=value arguments
+type cookieFlags []*http.Cookie
+
+func (c *cookieFlags) String() string {
+	if c == nil {
+		return ""
+	}
+
+	var parts []string
+	for _, cookie := range *c {
+		parts = append(parts, cookie.Name+"="+cookie.Value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (c *cookieFlags) Set(s string) error {
+	name, value, found := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return fmt.Errorf("malformed cookie %q, expected name=value", s)
+	}
+	*c = append(*c, &http.Cookie{Name: name, Value: strings.TrimSpace(value)})
+	return nil
+}
+
+// byteSizeFlag parses sizes such as 512, 64KB or 10MB, units are powers of 1024
+type byteSizeFlag int64
+
+func (b *byteSizeFlag) String() string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSizeFlag) Set(s string) error {
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	value, multiplier := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, multiplier = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.size
+			break
+		}
+	}
+
+	// a count that would overflow once scaled is as unusable as a typo
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/multiplier {
+		return fmt.Errorf("malformed size %q, expected a byte count such as 512, 64KB or 10MB", s)
+	}
+	*b = byteSizeFlag(n * multiplier)
+	return nil
+}
+
+// headerExpectation is one -expect-header assertion. "Name: value" needs an
+// exact value, "Name~value" a substring and a bare "Name" only the header
+type headerExpectation struct {
+	Name      string
+	Value     string
+	Substring bool
+	Present   bool
+}
+
+// expectHeaderFlags collects repeated -expect-header arguments
+type expectHeaderFlags []headerExpectation
+
+func (e *expectHeaderFlags) String() string {
+	if e == nil {
+		return ""
+	}
+
+	var parts []string
+	for _, exp := range *e {
+		parts = append(parts, exp.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (e *expectHeaderFlags) Set(s string) error {
+	exp := headerExpectation{Present: true}
+	if i := strings.IndexAny(s, ":~"); i >= 0 {
+		exp.Name, exp.Value = s[:i], strings.TrimSpace(s[i+1:])
+		exp.Substring, exp.Present = s[i] == '~', false
+	} else {
+		exp.Name = s
+	}
+
+	exp.Name = strings.TrimSpace(exp.Name)
+	if exp.Name == "" {
+		return fmt.Errorf("malformed header expectation %q, expected \"Name: value\", \"Name~value\" or \"Name\"", s)
+	}
+	*e = append(*e, exp)
+	return nil
+}
+
+func (e headerExpectation) String() string {
+	switch {
+	case e.Present:
+		return e.Name
+	case e.Substring:
+		return e.Name + "~" + e.Value
+	}
+	return e.Name + ": " + e.Value
+}
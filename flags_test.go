@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestByteSizeFlag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"512", 512, true},
+		{"64KB", 64 << 10, true},
+		{"10 mb", 10 << 20, true},
+		{"2GB", 2 << 30, true},
+		{"8589934591GB", 8589934591 << 30, true},
+		{"8589934592GB", 0, false},
+		{"9999999999GB", 0, false},
+		{"9223372036854775807", 9223372036854775807, true},
+		{"9223372036854775807KB", 0, false},
+		{"-1", 0, false},
+		{"ten", 0, false},
+	}
+	for _, tt := range tests {
+		var b byteSizeFlag
+		err := b.Set(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Set(%q) error = %v, want ok %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && int64(b) != tt.want {
+			t.Errorf("Set(%q) = %d, want %d", tt.in, b, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// output formats selectable with -format, everything but text moves the
+// human readable output to stderr
+const (
+	formatText       = "text"
+	formatJSON       = "json"
+	formatCSV        = "csv"
+	formatHAR        = "har"
+	formatPrometheus = "prometheus"
+	// one compact JSON object per target, written as soon as it finishes
+	formatJSONL = "jsonl"
+)
+
+var outputFormats = []string{formatText, formatJSON, formatJSONL, formatCSV, formatHAR, formatPrometheus}
+
+// resolveOutputFormat validates -format and folds in the older -json, -csv,
+// -har and -prometheus switches, which are kept as shorthands. legacy lists
+// the shorthands given, explicit is whether -format itself was
+func resolveOutputFormat(format string, explicit bool, legacy []string) (string, error) {
+	valid := false
+	for _, f := range outputFormats {
+		valid = valid || f == format
+	}
+	if !valid {
+		return "", fmt.Errorf("unknown -format %q, expected %s", format, strings.Join(outputFormats, ", "))
+	}
+
+	switch {
+	case len(legacy) == 0:
+		return format, nil
+	case len(legacy) > 1:
+		return "", fmt.Errorf("-%s cannot be combined, pick one with -format", strings.Join(legacy, " and -"))
+	case explicit && format != legacy[0]:
+		return "", fmt.Errorf("-%s conflicts with -format %s", legacy[0], format)
+	}
+	return legacy[0], nil
+}
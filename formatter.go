@@ -0,0 +1,185 @@
+package main
+
+import (
+	"io"
+	"sync"
+)
+
+// formatter renders the results of one target. textFormatter is the human
+// readable output, the others write the -format report
+type formatter interface {
+	FormatResponse(w io.Writer, infos []responseInfo, t *timmings) error
+	FormatSizes(w io.Writer, resources resourceMap) error
+}
+
+// formatters builds the formatter for each -format value. Prometheus is not
+// here, its metrics cover the whole run and runTargets writes them at the end
+var formatters = map[string]func(run runOptions) formatter{
+	formatText: func(run runOptions) formatter { return textFormatter{run: run} },
+	formatJSON: func(runOptions) formatter { return jsonFormatter{} },
+	formatCSV:  func(runOptions) formatter { return csvFormatter{} },
+	formatHAR:  func(runOptions) formatter { return harFormatter{} },
+}
+
+// textFormatter prints the analysis sections that follow a request, picked
+// by the run options
+type textFormatter struct {
+	run runOptions
+}
+
+// errWriter keeps the first error of a series of writes, the print helpers
+// ignore what Fprintln returns so it is checked once they are done
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// lockedWriter serializes writes to w from several goroutines
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// textTo points out, which the print helpers all write to, at w until the
+// returned restore is called
+func textTo(w io.Writer) (*errWriter, func()) {
+	stdout := out
+	ew := &errWriter{w: w}
+	out = ew
+	return ew, func() { out = stdout }
+}
+
+func (f textFormatter) FormatResponse(w io.Writer, infos []responseInfo, t *timmings) error {
+	ew, restore := textTo(w)
+	defer restore()
+
+	if len(infos) > 1 {
+		printRedirectChain(infos)
+	}
+	if len(t.CommonTimmings) > 0 {
+		printTimmingStats(t, len(infos), f.run.FullTiming, f.run.Thresholds)
+		if f.run.Waterfall {
+			printWaterfall(t.CommonTimmings[len(t.CommonTimmings)-1], t.ContentTransferTime)
+		}
+		if f.run.TraceDNS {
+			printDNSTrace(t.CommonTimmings)
+		}
+	}
+	if len(infos) > 0 {
+		final := infos[len(infos)-1].Response
+		if f.run.Security {
+			printSecurityHeaders(final)
+		}
+		if f.run.Cache {
+			printCacheAnalysis(final)
+		}
+		if f.run.Fingerprint {
+			printServerFingerprint(final)
+		}
+		if f.run.Range != nil {
+			printRangeSupport(final, *f.run.Range)
+		}
+	}
+	return ew.err
+}
+
+func (f textFormatter) FormatSizes(w io.Writer, resources resourceMap) error {
+	ew, restore := textTo(w)
+	defer restore()
+
+	printResourceSizes(resources, f.run.SizeOptions)
+	return ew.err
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) FormatResponse(w io.Writer, infos []responseInfo, t *timmings) error {
+	return writeJSON(w, buildJSONReport(infos, t, nil))
+}
+
+func (jsonFormatter) FormatSizes(w io.Writer, resources resourceMap) error {
+	return writeJSON(w, buildJSONReport(nil, &timmings{}, resources))
+}
+
+// csvFormatter only has a layout for size mode
+type csvFormatter struct{}
+
+func (csvFormatter) FormatResponse(io.Writer, []responseInfo, *timmings) error {
+	return nil
+}
+
+func (csvFormatter) FormatSizes(w io.Writer, resources resourceMap) error {
+	return writeResourceCSV(w, resources)
+}
+
+// harFormatter has nothing to say about resource sizes, HAR entries are requests
+type harFormatter struct{}
+
+func (harFormatter) FormatResponse(w io.Writer, infos []responseInfo, t *timmings) error {
+	if len(infos) == 0 {
+		return nil
+	}
+	har, err := buildHAR(infos, t)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, har)
+}
+
+func (harFormatter) FormatSizes(io.Writer, resourceMap) error {
+	return nil
+}
+
+// runReport collects the json or har report of every target of a run with
+// several targets, runTargets writes them at the end as one JSON array or
+// one HAR log the way it does the prometheus metrics
+type runReport struct {
+	format  string
+	targets []jsonLine
+	entries []harEntry
+}
+
+func (r *runReport) add(urlArg string, infos []responseInfo, t *timmings, resources resourceMap, err error) error {
+	switch r.format {
+	case formatJSON:
+		r.targets = append(r.targets, newJSONLine(urlArg, infos, t, resources, err))
+	case formatHAR:
+		if len(infos) == 0 {
+			return nil
+		}
+		har, harErr := buildHAR(infos, t)
+		if harErr != nil {
+			return harErr
+		}
+		r.entries = append(r.entries, har.Log.Entries...)
+	}
+	return nil
+}
+
+func (r *runReport) write(w io.Writer) error {
+	if r.format == formatHAR {
+		har := newHARDocument()
+		har.Log.Entries = append(har.Log.Entries, r.entries...)
+		return writeJSON(w, har)
+	}
+	targets := r.targets
+	if targets == nil {
+		targets = []jsonLine{}
+	}
+	return writeJSON(w, targets)
+}
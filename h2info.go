@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+)
+
+// printHTTP2Details adds what can be seen of an HTTP/2 exchange. net/http
+// keeps the frames to itself, so SETTINGS and stream ids are out of reach;
+// this reports the exact version, how it was negotiated and whether the
+// stream rode on an existing connection. HTTP/1.x and HTTP/3 print nothing
+func printHTTP2Details(t timmingsCommon) {
+	if t.ProtoMajor != 2 {
+		return
+	}
+
+	fmt.Fprintln(out, au.Green("HTTP/2 details"))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Version"), au.Blue(fmt.Sprintf("HTTP/%d.%d", t.ProtoMajor, t.ProtoMinor)))
+
+	negotiated := "ALPN " + t.ALPNProtocol
+	if t.ALPNProtocol == "" {
+		// only cleartext prior knowledge gets h2 without ALPN
+		negotiated = "without ALPN"
+	}
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Negotiated"), au.Blue(negotiated))
+
+	stream := "first stream of a new connection"
+	if t.ConnectionReused {
+		stream = "new stream on a reused connection"
+	}
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Stream"), au.Blue(stream))
+	// the Go client sends SETTINGS_ENABLE_PUSH=0, so no PUSH_PROMISE can arrive
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Server push"), au.Blue("disabled by the client, response was not pushed"))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// HAR 1.2, see http://www.softwareishard.com/blog/har-12-spec/
+type harDocument struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harTimings are in milliseconds, -1 marks a phase that does not apply
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// buildHAR converts the collected responses and their connection timings into
+// a HAR document. Each hop of a redirect chain becomes its own entry.
+func newHARDocument() harDocument {
+	return harDocument{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "headview", Version: appVersion},
+		Entries: []harEntry{},
+	}}
+}
+
+func buildHAR(infos []responseInfo, t *timmings) (harDocument, error) {
+	if len(infos) == 0 {
+		return harDocument{}, errors.New("no responses to export")
+	}
+
+	doc := newHARDocument()
+	for i, info := range infos {
+		var conn timmingsCommon
+		if i < len(t.CommonTimmings) {
+			conn = t.CommonTimmings[i]
+		}
+
+		timings := harTimings{
+			Blocked: -1,
+			DNS:     harMillis(conn.DNSLookupTime),
+			Connect: harMillis(conn.TCPConnTime + conn.TLSHandshakeTime),
+			SSL:     harMillis(conn.TLSHandshakeTime),
+			Wait:    harMillis(conn.TTFB),
+		}
+		if i == len(infos)-1 {
+			timings.Receive = harMillis(t.ContentTransferTime)
+		}
+
+		resp := info.Response
+		entry := harEntry{
+			StartedDateTime: info.Started.Format(time.RFC3339Nano),
+			Time:            timings.DNS + timings.Connect + timings.Send + timings.Wait + timings.Receive,
+			Request:         buildHARRequest(resp.Request),
+			Response: harResponse{
+				Status:      resp.StatusCode,
+				StatusText:  http.StatusText(resp.StatusCode),
+				HTTPVersion: resp.Proto,
+				Cookies:     []harNameValue{},
+				Headers:     harHeaders(resp.Header),
+				Content: harContent{
+					Size:     info.ContentSize,
+					MimeType: resp.Header.Get("Content-Type"),
+				},
+				RedirectURL: resp.Header.Get("Location"),
+				HeadersSize: -1,
+				BodySize:    info.WireSize,
+			},
+			Timings: timings,
+		}
+		// the SSL time is already part of connect, so drop it from the total
+		entry.Time -= timings.SSL
+
+		doc.Log.Entries = append(doc.Log.Entries, entry)
+	}
+
+	return doc, nil
+}
+
+func buildHARRequest(req *http.Request) harRequest {
+	harReq := harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(req.Header),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    0,
+	}
+
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			harReq.QueryString = append(harReq.QueryString, harNameValue{Name: key, Value: value})
+		}
+	}
+
+	return harReq
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := []harNameValue{}
+	for key, values := range header {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: key, Value: value})
+		}
+	}
+	return headers
+}
+
+func harMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
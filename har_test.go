@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuildHARShape(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new?q=1&q=2", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	stdout := out
+	out = io.Discard
+	defer func() { out = stdout }()
+	timeStats, responses = timmings{}, nil
+	opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10}
+	if err := performGetRequest(srv.Client(), srv.URL+"/old", opts); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := buildHAR(responses, &timeStats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decode into plain maps so the test sees the field names a HAR viewer does
+	var har struct {
+		Log struct {
+			Version string
+			Creator map[string]string
+			Entries []map[string]json.RawMessage
+		}
+	}
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatal(err)
+	}
+	if har.Log.Version != "1.2" || har.Log.Creator["name"] != "headview" || har.Log.Creator["version"] != appVersion {
+		t.Errorf("log header is %q by %v", har.Log.Version, har.Log.Creator)
+	}
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("got %d entries, want one per hop", len(har.Log.Entries))
+	}
+
+	for i, entry := range har.Log.Entries {
+		for _, key := range []string{"startedDateTime", "time", "request", "response", "cache", "timings"} {
+			if _, ok := entry[key]; !ok {
+				t.Errorf("entry %d has no %q", i, key)
+			}
+		}
+		var started string
+		json.Unmarshal(entry["startedDateTime"], &started)
+		if _, err := time.Parse(time.RFC3339Nano, started); err != nil {
+			t.Errorf("entry %d startedDateTime %q: %v", i, started, err)
+		}
+
+		// the spec wants these as arrays, never null
+		var req, resp map[string]json.RawMessage
+		json.Unmarshal(entry["request"], &req)
+		json.Unmarshal(entry["response"], &resp)
+		for _, list := range []json.RawMessage{req["cookies"], req["headers"], req["queryString"], resp["cookies"], resp["headers"]} {
+			if len(list) == 0 || list[0] != '[' {
+				t.Errorf("entry %d has %s where a list belongs", i, list)
+			}
+		}
+
+		var timings map[string]float64
+		json.Unmarshal(entry["timings"], &timings)
+		for _, key := range []string{"blocked", "dns", "connect", "send", "wait", "receive", "ssl"} {
+			if _, ok := timings[key]; !ok {
+				t.Errorf("entry %d timings have no %q", i, key)
+			}
+		}
+		if timings["blocked"] != -1 {
+			t.Errorf("entry %d blocked is %v, want -1 for not measured", i, timings["blocked"])
+		}
+	}
+
+	first, last := doc.Log.Entries[0], doc.Log.Entries[1]
+	if first.Response.Status != http.StatusMovedPermanently || first.Response.RedirectURL != "/new?q=1&q=2" {
+		t.Errorf("first hop is %d to %q", first.Response.Status, first.Response.RedirectURL)
+	}
+	if got := last.Request.QueryString; len(got) != 2 || got[0].Value != "1" || got[1].Value != "2" {
+		t.Errorf("queryString is %v, want q=1 and q=2", got)
+	}
+	if last.Response.Content.Size != 5 || last.Response.Content.MimeType != "text/plain" {
+		t.Errorf("content is %+v", last.Response.Content)
+	}
+
+	if _, err := buildHAR(nil, &timmings{}); err == nil {
+		t.Error("buildHAR accepted no responses")
+	}
+}
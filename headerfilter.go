@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// parseHeaderFilter splits a -header-filter list into lowercase patterns.
+// Each is a header name or a glob such as X-*
+func parseHeaderFilter(s string) ([]string, error) {
+	var patterns []string
+	for _, part := range strings.Split(s, ",") {
+		pattern := strings.ToLower(strings.TrimSpace(part))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid header pattern %q", part)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// headerMatches reports whether name passes the filter, header names are
+// compared case-insensitively and an empty filter passes everything
+func headerMatches(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hstsMinMaxAge is the shortest max-age treated as a strong policy, 6 months
+const hstsMinMaxAge = 180 * 24 * time.Hour
+
+type hstsPolicy struct {
+	MaxAge            time.Duration
+	HasMaxAge         bool
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+// parseHSTS reads the directives of a Strict-Transport-Security value,
+// directive names are case insensitive and max-age may be quoted
+func parseHSTS(value string) hstsPolicy {
+	var policy hstsPolicy
+	for _, directive := range strings.Split(value, ";") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			if seconds, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(arg), `"`), 10, 64); err == nil && seconds >= 0 {
+				policy.MaxAge = time.Duration(seconds) * time.Second
+				policy.HasMaxAge = true
+			}
+		case "includesubdomains":
+			policy.IncludeSubDomains = true
+		case "preload":
+			policy.Preload = true
+		}
+	}
+	return policy
+}
+
+// hstsWarnings lists what keeps a policy from being strong
+func hstsWarnings(policy hstsPolicy) []string {
+	var warnings []string
+	switch {
+	case !policy.HasMaxAge:
+		warnings = append(warnings, "max-age is missing or invalid, browsers ignore the header")
+	case policy.MaxAge == 0:
+		warnings = append(warnings, "max-age=0 tells browsers to forget the policy")
+	case policy.MaxAge < hstsMinMaxAge:
+		warnings = append(warnings, fmt.Sprintf("max-age of %d days is below 6 months", int(policy.MaxAge.Hours()/24)))
+	}
+	if policy.Preload && !policy.IncludeSubDomains {
+		warnings = append(warnings, "preload is claimed without includeSubDomains, preload lists will reject it")
+	}
+	return warnings
+}
+
+func printHSTS(resp *http.Response) {
+	value := resp.Header.Get("Strict-Transport-Security")
+	if value == "" {
+		return
+	}
+
+	policy := parseHSTS(value)
+	fmt.Fprintln(out, au.Green("HSTS policy:"))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("max-age"), au.Blue(fmt.Sprintf("%d days", int(policy.MaxAge.Hours()/24))))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("includeSubDomains"), au.Blue(policy.IncludeSubDomains))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("preload"), au.Blue(policy.Preload))
+
+	warnings := hstsWarnings(policy)
+	for _, warning := range warnings {
+		fmt.Fprintln(out, au.Yellow("Warning:"), au.Yellow(warning))
+	}
+	switch {
+	case len(warnings) > 0:
+		fmt.Fprintln(out, au.Green("HSTS strength:"), au.Red("weak"))
+	case !policy.IncludeSubDomains:
+		fmt.Fprintln(out, au.Green("HSTS strength:"), au.Yellow("good, subdomains are not covered"))
+	default:
+		fmt.Fprintln(out, au.Green("HSTS strength:"), au.Green("strong"))
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// plainHTTPURL inverts addDefaultProtocol, giving the http:// form of a
+// target. An explicit :443 is dropped since it is just the https default,
+// any other port is kept, so a site on https://host:8443 is probed at
+// http://host:8443 and only passes if that port also answers plain http
+func plainHTTPURL(s string) string {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "http://")
+	u, err := url.Parse("http://" + s)
+	if err != nil {
+		return "http://" + s
+	}
+	if u.Port() == "443" {
+		u.Host = strings.TrimSuffix(u.Host, ":443")
+	}
+	return u.String()
+}
+
+// checkHTTPSRedirects requests the plain http form of each target and checks
+// that the first response redirects to https, preferably permanently, and
+// that the final https response sends HSTS. Returns 1 when any target fails
+func checkHTTPSRedirects(client *http.Client, targets []string, opts requestOptions) int {
+	exitCode := 0
+	opts.NoRedirect = false
+
+	for _, target := range targets {
+		probe := plainHTTPURL(target)
+		fmt.Fprintln(out, au.Magenta("Checking HTTPS redirect for:"), au.Cyan(displayURL(probe)))
+
+		timeStats = timmings{}
+		responses = nil
+		stdout := out
+		out = io.Discard
+		err := performGetRequest(client, probe, opts)
+		out = stdout
+
+		if len(responses) == 0 {
+			fmt.Fprintln(out, au.Red("FAIL:"), au.Red(err))
+			exitCode = 1
+			continue
+		}
+		if len(responses) > 1 {
+			printRedirectChain(responses)
+		}
+
+		problems, notes := httpsRedirectFindings(probe, responses)
+		for _, note := range notes {
+			fmt.Fprintln(out, au.Yellow("Note:"), au.Yellow(note))
+		}
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Fprintln(out, au.Red("FAIL:"), au.Red(problem))
+			}
+			exitCode = 1
+		} else {
+			fmt.Fprintln(out, au.Green("PASS:"), au.Green("plain http redirects to https"))
+		}
+		fmt.Fprintln(out)
+	}
+	return exitCode
+}
+
+// httpsRedirectFindings returns what fails the check and what only deserves a mention
+func httpsRedirectFindings(probe string, infos []responseInfo) (problems, notes []string) {
+	first := infos[0].Response
+	if first.StatusCode < 300 || first.StatusCode >= 400 {
+		return []string{fmt.Sprintf("plain http answered %s instead of redirecting", first.Status)}, nil
+	}
+
+	location, err := first.Location()
+	if err != nil {
+		return []string{"redirect has no usable Location header"}, nil
+	}
+	if location.Scheme != "https" {
+		problems = append(problems, "first redirect goes to "+location.String()+", not https")
+	}
+
+	probeURL, err := url.Parse(probe)
+	if err == nil && !strings.EqualFold(probeURL.Hostname(), location.Hostname()) {
+		notes = append(notes, "redirects to a different host: "+location.Hostname())
+	}
+	if first.StatusCode != http.StatusMovedPermanently && first.StatusCode != http.StatusPermanentRedirect {
+		notes = append(notes, fmt.Sprintf("redirect is %d, a permanent 301 or 308 is preferred", first.StatusCode))
+	}
+
+	final := infos[len(infos)-1]
+	if strings.HasPrefix(final.URL, "https://") && final.Response.Header.Get("Strict-Transport-Security") == "" {
+		notes = append(notes, "final https response does not send Strict-Transport-Security")
+	}
+	return problems, notes
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestPlainHTTPURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/a?b=c", "http://example.com/a?b=c"},
+		{"https://example.com:443/", "http://example.com/"},
+		{"https://example.com:8443/", "http://example.com:8443/"},
+		{"http://[2001:db8::1]:443/x", "http://[2001:db8::1]/x"},
+		{"example.com", "http://example.com"},
+	}
+	for _, tt := range tests {
+		if got := plainHTTPURL(tt.in); got != tt.want {
+			t.Errorf("plainHTTPURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// iconsResourceType groups icons a browser loads without the page listing
+// them as ordinary resources: /favicon.ico and web app manifest icons
+const iconsResourceType = "icons"
+
+// collectIcons probes /favicon.ico and fetches the icons of every linked web
+// app manifest, skipping anything the page already referenced
+func collectIcons(doc *goquery.Document, baseURL *url.URL, c *resourceCollector) {
+	c.collectIcon("/favicon.ico", baseURL)
+
+	doc.Find("link[rel~='manifest'][href]").Each(func(_ int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		ref, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+		manifestURL := baseURL.ResolveReference(ref)
+		for _, src := range fetchManifestIcons(manifestURL.String(), c.client, c.opts) {
+			c.collectIcon(src, manifestURL)
+		}
+	})
+}
+
+// collectIcon adds an icon under iconsResourceType. Unlike page references a
+// missing icon is not an error, browsers probe for them, so 4xx and 5xx are dropped
+func (c *resourceCollector) collectIcon(link string, baseURL *url.URL) {
+	ref, err := url.Parse(link)
+	if err != nil {
+		return
+	}
+	resolved := baseURL.ResolveReference(ref)
+	// an icon the page references itself is already listed, but the probe
+	// is not a reference of its own
+	c.mu.Lock()
+	seen := c.references[resolved.String()] > 0
+	if !seen {
+		c.references[resolved.String()] = 1
+		c.order[resolved.String()] = len(c.order)
+	}
+	c.mu.Unlock()
+	if seen || !robotsAllowed(c.opts, resolved) {
+		return
+	}
+
+	c.fetch(resolved.String(), baseURL, func(res *resource, _ []byte) {
+		if res.Status < 400 {
+			c.add(iconsResourceType, *res)
+		}
+	})
+}
+
+// webManifest is the part of a web app manifest that lists icons
+type webManifest struct {
+	Icons []struct {
+		Src string `json:"src"`
+	} `json:"icons"`
+}
+
+// fetchManifestIcons returns the icon sources of a manifest, relative to the
+// manifest URL as the spec resolves them
+func fetchManifestIcons(manifestURL string, client *http.Client, opts sizeOptions) []string {
+	req, err := http.NewRequestWithContext(opts.context(), "GET", manifestURL, nil)
+	if err != nil {
+		return nil
+	}
+	setAcceptEncoding(req)
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(out, au.Red("Error fetching manifest:"), au.Red(err))
+		return nil
+	}
+	defer resp.Body.Close()
+
+	wire, truncated, err := readBody(resp.Body, opts.MaxBody)
+	if err != nil || truncated || resp.StatusCode >= 400 {
+		return nil
+	}
+	body, err := decodeBody(resp.Header.Get("Content-Encoding"), wire)
+	if err != nil {
+		return nil
+	}
+
+	var manifest webManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		fmt.Fprintln(out, au.Red("Error parsing manifest:"), au.Red(err))
+		return nil
+	}
+
+	var icons []string
+	for _, icon := range manifest.Icons {
+		if icon.Src != "" {
+			icons = append(icons, icon.Src)
+		}
+	}
+	return icons
+}
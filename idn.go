@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// toASCIIURL punycodes an internationalized host so the dial, SNI and Host
+// header all carry the A-label form. ASCII hosts and URLs that do not parse
+// are returned unchanged
+func toASCIIURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+
+	host := u.Hostname()
+	if isASCII(host) {
+		return s
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return s
+	}
+
+	if port := u.Port(); port != "" {
+		ascii = net.JoinHostPort(ascii, port)
+	}
+	u.Host = ascii
+	return u.String()
+}
+
+// displayURL turns a punycoded host back into Unicode for human output,
+// reports keep the A-label form that was actually requested. Unix socket
+// placeholders get their http+unix:// form back
+func displayURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	if unix, ok := unixDisplayURL(u); ok {
+		return unix
+	}
+	if !strings.Contains(u.Host, "xn--") {
+		return s
+	}
+
+	host, err := idna.Display.ToUnicode(u.Hostname())
+	if err != nil {
+		return s
+	}
+	return replaceHostname(s, u.Hostname(), host)
+}
+
+// replaceHostname swaps hostname for host in the authority of s, leaving
+// userinfo, port and the rest of the URL exactly as given. url.URL.String
+// would percent-encode a Unicode host, so the string is edited in place
+func replaceHostname(s, hostname, host string) string {
+	start := strings.Index(s, "//")
+	if start < 0 {
+		return s
+	}
+	start += len("//")
+	end := len(s)
+	if i := strings.IndexAny(s[start:], "/?#"); i >= 0 {
+		end = start + i
+	}
+	// userinfo may itself contain the hostname, the host follows its last @
+	if at := strings.LastIndex(s[start:end], "@"); at >= 0 {
+		start += at + 1
+	}
+	if !strings.HasPrefix(s[start:end], hostname) {
+		return s
+	}
+	return s[:start] + host + s[start+len(hostname):]
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestToASCIIURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/path?q=1", "https://example.com/path?q=1"},
+		{"https://bücher.de/", "https://xn--bcher-kva.de/"},
+		{"https://bücher.de:8443/a", "https://xn--bcher-kva.de:8443/a"},
+		// only the host is converted, the same name in the path is left alone
+		{"https://user@bücher.de/bücher.de", "https://user@xn--bcher-kva.de/b%C3%BCcher.de"},
+		{"https://mañana.example.com/", "https://xn--maana-pta.example.com/"},
+		// Latin with a Cyrillic а, the homograph ends up visibly punycoded
+		{"https://p\u0430ypal.com/", "https://xn--pypal-4ve.com/"},
+	}
+	for _, tt := range tests {
+		if got := toASCIIURL(tt.in); got != tt.want {
+			t.Errorf("toASCIIURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/path?q=1", "https://example.com/path?q=1"},
+		{"https://xn--bcher-kva.de/", "https://bücher.de/"},
+		{"https://xn--bcher-kva.de:8443/a", "https://bücher.de:8443/a"},
+		// userinfo holding the A-label must not be rewritten
+		{"https://xn--bcher-kva.de@xn--bcher-kva.de/", "https://xn--bcher-kva.de@bücher.de/"},
+		{"https://xn--maana-pta.example.com/?u=xn--maana-pta", "https://mañana.example.com/?u=xn--maana-pta"},
+	}
+	for _, tt := range tests {
+		if got := displayURL(tt.in); got != tt.want {
+			t.Errorf("displayURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
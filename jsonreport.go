@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// jsonReport is the flattened, serializable form of a headview run.
+// Durations are emitted as integer nanoseconds.
+type jsonReport struct {
+	Responses []jsonResponse            `json:"responses,omitempty"`
+	Timings   *jsonTimings              `json:"timings,omitempty"`
+	Resources map[string][]jsonResource `json:"resources,omitempty"`
+}
+
+type jsonResponse struct {
+	URL            string      `json:"url"`
+	Status         string      `json:"status"`
+	StatusCode     int         `json:"status_code"`
+	StatusText     string      `json:"status_text"`
+	Proto          string      `json:"proto"`
+	Headers        http.Header `json:"headers"`
+	RequestHeaders http.Header `json:"request_headers,omitempty"`
+	ContentSize    int64       `json:"content_size"`
+	WireSize       int64       `json:"wire_size"`
+	HeaderSize     int64       `json:"header_size"`
+	Attempts       int         `json:"attempts"`
+	BodyHash       string      `json:"body_hash,omitempty"`
+	Truncated      bool        `json:"truncated,omitempty"`
+}
+
+type jsonTimings struct {
+	Connections          []jsonConnection `json:"connections"`
+	RequestSendingTime   int64            `json:"request_sending_ns"`
+	ServerProcessingTime int64            `json:"server_processing_ns"`
+	ContentTransferTime  int64            `json:"content_transfer_ns"`
+	TotalRequestTime     int64            `json:"total_request_ns"`
+}
+
+type jsonConnection struct {
+	DNSLookupTime    int64    `json:"dns_lookup_ns"`
+	TCPConnTime      int64    `json:"tcp_connection_ns"`
+	TLSHandshakeTime int64    `json:"tls_handshake_ns"`
+	TTFB             int64    `json:"ttfb_ns"`
+	ResolvedIPs      []string `json:"resolved_ips,omitempty"`
+}
+
+type jsonResource struct {
+	URL        string `json:"url"`
+	Size       int64  `json:"size"`
+	WireSize   int64  `json:"wire_size"`
+	Count      int    `json:"count"`
+	Hash       string `json:"hash,omitempty"`
+	ThirdParty bool   `json:"third_party"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	TTFB       int64  `json:"ttfb_ns,omitempty"`
+}
+
+// statusLine is the single line -status-line writes to stderr per target
+type statusLine struct {
+	URL     string  `json:"url"`
+	Status  int     `json:"status"`
+	Error   string  `json:"error"`
+	TotalMs float64 `json:"total_ms"`
+}
+
+func writeStatusLine(w io.Writer, urlArg string, final *responseInfo, total time.Duration, err error) error {
+	line := statusLine{URL: urlArg, TotalMs: float64(total.Microseconds()) / 1000}
+	if final != nil {
+		line.Status = final.StatusCode
+	}
+	if err != nil {
+		line.Error = err.Error()
+	}
+
+	data, marshalErr := json.Marshal(line)
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal status line: %v", marshalErr)
+	}
+	_, writeErr := fmt.Fprintln(w, string(data))
+	return writeErr
+}
+
+// jsonLine is one -format jsonl record. A target that failed still gets its
+// line, with error set and whatever was gathered before the failure
+type jsonLine struct {
+	URL   string `json:"url"`
+	Error string `json:"error,omitempty"`
+	jsonReport
+}
+
+// writeJSONLine writes the line in a single call, so an unbuffered stdout or
+// -o file hands it on before the next target starts
+func writeJSONLine(w io.Writer, urlArg string, infos []responseInfo, t *timmings, resMap resourceMap, err error) error {
+	data, marshalErr := json.Marshal(newJSONLine(urlArg, infos, t, resMap, err))
+	if marshalErr != nil {
+		return fmt.Errorf("failed to marshal line: %v", marshalErr)
+	}
+	_, writeErr := w.Write(append(data, '\n'))
+	return writeErr
+}
+
+func newJSONLine(urlArg string, infos []responseInfo, t *timmings, resMap resourceMap, err error) jsonLine {
+	line := jsonLine{URL: urlArg, jsonReport: buildJSONReport(infos, t, resMap)}
+	if err != nil {
+		line.Error = err.Error()
+	}
+	return line
+}
+
+func buildJSONReport(infos []responseInfo, t *timmings, resMap resourceMap) jsonReport {
+	var report jsonReport
+
+	for _, info := range infos {
+		report.Responses = append(report.Responses, jsonResponse{
+			URL:            info.URL,
+			Status:         info.Response.Status,
+			StatusCode:     info.StatusCode,
+			StatusText:     info.StatusText,
+			Proto:          info.Response.Proto,
+			Headers:        info.Response.Header,
+			RequestHeaders: info.RequestHeaders,
+			ContentSize:    info.ContentSize,
+			WireSize:       info.WireSize,
+			HeaderSize:     info.HeaderSize,
+			Attempts:       info.Attempts,
+			BodyHash:       info.BodyHash,
+			Truncated:      info.Truncated,
+		})
+	}
+
+	if len(t.CommonTimmings) > 0 {
+		report.Timings = &jsonTimings{
+			RequestSendingTime:   t.RequestSendingTime.Nanoseconds(),
+			ServerProcessingTime: t.ServerProcessingTime.Nanoseconds(),
+			ContentTransferTime:  t.ContentTransferTime.Nanoseconds(),
+			TotalRequestTime:     t.TotalRequestTime.Nanoseconds(),
+		}
+		for _, c := range t.CommonTimmings {
+			report.Timings.Connections = append(report.Timings.Connections, jsonConnection{
+				DNSLookupTime:    c.DNSLookupTime.Nanoseconds(),
+				TCPConnTime:      c.TCPConnTime.Nanoseconds(),
+				TLSHandshakeTime: c.TLSHandshakeTime.Nanoseconds(),
+				TTFB:             c.TTFB.Nanoseconds(),
+				ResolvedIPs:      c.ResolvedIPs,
+			})
+		}
+	}
+
+	if len(resMap) > 0 {
+		report.Resources = make(map[string][]jsonResource)
+		for resType, resources := range resMap {
+			for _, r := range resources {
+				res := jsonResource{URL: r.URL, Size: r.Size, WireSize: r.WireSize, Count: r.Count, Hash: r.Hash, ThirdParty: r.ThirdParty, Truncated: r.Truncated}
+				if r.Timing != nil {
+					res.TTFB = r.Timing.TTFB.Nanoseconds()
+				}
+				report.Resources[resType] = append(report.Resources[resType], res)
+			}
+		}
+	}
+
+	return report
+}
+
+func writeJSON(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %v", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
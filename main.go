@@ -1,103 +1,975 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptrace"
+	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/guptarohit/asciigraph"
 	"github.com/logrusorgru/aurora"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+	"golang.org/x/term"
+	"golang.org/x/time/rate"
 )
 
 func main() {
-	// Check if URL is provided
-	if len(os.Args) < 2 {
-		fmt.Println("Please provide a URL as the first argument.")
-		return
+	// Get URL from the first argument, unless we start straight with flags
+	args := os.Args[1:]
+	var targets []string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		targets = append(targets, addDefaultProtocol(args[0]))
+		args = args[1:]
 	}
 
-	// Get URL from the first argument
-	urlArg := addDefaultProtocol(os.Args[1])
+	// the config file seeds flag defaults, so it is read before they are declared
+	configPath, explicitConfig := configPathFromArgs(args)
+	cfg, cfgErr := loadConfig(configPath, explicitConfig)
+	if cfgErr != nil {
+		fmt.Fprintln(os.Stderr, au.Red(cfgErr))
+		os.Exit(2)
+	}
+	defaultTimeout := 30 * time.Second
+	if cfg.Timeout > 0 {
+		defaultTimeout = cfg.Timeout
+	}
+	defaultConcurrency := 6
+	if cfg.Concurrency > 0 {
+		defaultConcurrency = cfg.Concurrency
+	}
 
 	// Create a new flag set to parse the remaining arguments
 	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags.String("config", "", "Config file with default settings (default ~/.headview.yaml), flags override it")
 
 	// Define the rest of your flags
 	headersArg := flags.Bool("headers", false, "Print headers")
+	headerFilterArg := flags.String("header-filter", "", "Only print response headers matching this comma separated list of names or globs like X-* (implies -headers)")
+	requestHeadersArg := flags.Bool("request-headers", false, "Print the request headers as sent, including User-Agent and -H")
 	sizeArg := flags.Bool("size", false, "Calculate size of resources")
 	verArg := flags.Bool("v", false, "Print version information")
+	verboseArg := flags.Bool("verbose", false, "Log what headview is doing to stderr as it happens")
+	formatArg := flags.String("format", formatText, "Output format: text, json (an array with several targets), jsonl (a line per target, written as it completes), csv (size mode only), har or prometheus, anything but text moves human output to stderr")
+	jsonArg := flags.Bool("json", false, "Shorthand for -format json")
+	methodArg := flags.String("method", http.MethodHead, "HTTP method to use (HEAD responses have no body, so content transfer is ~0)")
+	dataArg := flags.String("data", "", "Request body, @file reads it from a file and @- from stdin (defaults the method to POST)")
+	contentTypeArg := flags.String("content-type", "application/octet-stream", "Content-Type sent with -data")
+	followMetaRefreshArg := flags.Bool("follow-meta-refresh", false, "Follow <meta http-equiv=\"refresh\"> redirects in 200 HTML pages (switches the default method to GET)")
+	noRedirectArg := flags.Bool("no-redirect", false, "Do not follow 3xx redirects")
+	maxRedirectsArg := flags.Int("max-redirects", 10, "Maximum number of redirects to follow (0 follows none)")
+	timeoutArg := flags.Duration("timeout", defaultTimeout, "Overall request timeout (e.g. 5s, 1m)")
+	insecureArg := flags.Bool("insecure", cfg.Insecure, "Skip TLS certificate verification")
+	inputFileArg := flags.String("input-file", "", "Read newline separated URLs from a file (- for stdin)")
+	harArg := flags.Bool("har", false, "Shorthand for -format har")
+	openArg := flags.Bool("open", false, "Write a HAR of each target to a temp file and open it in the default viewer")
+	prometheusArg := flags.Bool("prometheus", false, "Shorthand for -format prometheus")
+	csvArg := flags.Bool("csv", false, "Shorthand for -format csv")
+	bearerArg := flags.String("bearer", "", "Send Authorization: Bearer with this token, @file reads it from a file and env:NAME from the environment")
+	userArg := flags.String("u", "", "Basic auth credentials user:password (prompts when the password is omitted)")
+	depthArg := flags.Int("depth", 0, "Follow url() and @import references in stylesheets this many levels deep in size mode")
+	selectArg := flags.String("select", "", "CSS selector for the elements size mode fetches (default covers link, script, img, source, video, audio, iframe)")
+	respectRobotsArg := flags.Bool("respect-robots", false, "Skip resources the page host's robots.txt disallows in size mode")
+	concurrencyArg := flags.Int("concurrency", defaultConcurrency, "Fetch this many resources at once in size mode, like a browser's connections per host, or pages at once with -sitemap")
+	accurateArg := flags.Bool("accurate", false, "Download every resource in size mode instead of using Content-Length from HEAD")
+	noColorArg := flags.Bool("no-color", false, "Disable colored output")
+	colorArg := flags.Bool("color", false, "Force colored output even when not writing to a terminal")
+	outputArg := flags.String("o", "", "Write output to a file instead of stdout")
+	waterfallArg := flags.Bool("waterfall", false, "Print a waterfall chart of the request phases")
+	dnsOnlyArg := flags.Bool("dns-only", false, "Only resolve the host, honoring -4, -6 and -resolve, and report the time and A/AAAA records")
+	ttfbGoodArg := flags.Duration("ttfb-good", 100*time.Millisecond, "TTFB and total request time under this are shown green")
+	ttfbBadArg := flags.Duration("ttfb-bad", 500*time.Millisecond, "TTFB and total request time over this are shown red, in between yellow")
+	fullTimingArg := flags.Bool("full-timing", false, "List every connection phase, including the zero ones a reused or plain HTTP connection skips")
+	traceDNSArg := flags.Bool("trace-dns", false, "Print a detailed breakdown of the DNS phase")
+	ipv4Arg := flags.Bool("4", false, "Connect over IPv4 only")
+	ipv6Arg := flags.Bool("6", false, "Connect over IPv6 only")
+	proxyArg := flags.String("proxy", "", "Proxy URL (http, https or socks5), defaults to HTTP_PROXY/HTTPS_PROXY/NO_PROXY")
+	resolveArgs := make(resolveFlags)
+	flags.Var(resolveArgs, "resolve", "Pin a host to an IP as host:ip, skipping DNS (repeatable)")
+	securityArg := flags.Bool("security", false, "Report security related response headers and a grade")
+	rangeArg := flags.String("range", "", "Request a byte range as start-end, start- or -suffix and check the server honours it with 206")
+	cacheArg := flags.Bool("cache", false, "Interpret caching headers and report freshness")
+	fingerprintArg := flags.Bool("fingerprint", false, "Guess the CDN and origin server stack from response headers")
+	certArg := flags.String("cert", "", "Client certificate PEM file for mutual TLS")
+	keyArg := flags.String("key", "", "Client private key PEM file for mutual TLS")
+	hostArg := flags.String("host", "", "Override the Host header, combine with -resolve to reach a specific backend")
+	sniArg := flags.String("sni", "", "Override the TLS server name (defaults to -host when given)")
+	failOnStatusArg := flags.String("fail-on-status", "", "Exit non-zero when the final status matches, e.g. 4xx,5xx or 404")
+	expectStatusArg := flags.String("expect-status", "", "Assert the final status, e.g. 200 or 2xx, printing PASS or FAIL and exiting 1 on FAIL")
+	var expectHeaderArgs expectHeaderFlags
+	flags.Var(&expectHeaderArgs, "expect-header", "Assert a final response header: \"Name: value\" exact, \"Name~value\" substring, \"Name\" present (repeatable)")
+	maxTTFBArg := flags.Duration("max-ttfb", 0, "Exit non-zero when TTFB exceeds this duration")
+	minTLSArg := flags.String("min-tls", "", "Minimum TLS version to offer: 1.0, 1.1, 1.2 or 1.3")
+	maxTLSArg := flags.String("max-tls", "", "Maximum TLS version to offer: 1.0, 1.1, 1.2 or 1.3")
+	http3Arg := flags.Bool("http3", false, "Use HTTP/3 over QUIC (DNS/TCP/TLS phase timings are unavailable)")
+	forceHTTP1Arg := flags.Bool("force-http1", false, "Disable HTTP/2 negotiation and speak HTTP/1.1 only")
+	noKeepAliveArg := flags.Bool("no-keepalive", false, "Open a fresh connection for every request, so each one pays DNS, TCP and TLS")
+	saveBodyArg := flags.String("save-body", "", "Write the final response body to a file (- for stdout), use with -method GET")
+	var maxBodyArg byteSizeFlag
+	flags.Var(&maxBodyArg, "max-body", "Stop reading bodies after this size, e.g. 10MB (default unlimited, -size holds each resource body in memory), also cuts short -save-body")
+	var minSizeArg byteSizeFlag
+	flags.Var(&minSizeArg, "min-size", "In size mode, only list resources of at least this size, e.g. 50KB (totals still count all)")
+	resourceTimingArg := flags.Bool("resource-timing", false, "In size mode, trace every resource fetch and list the slowest by TTFB (adds overhead)")
+	topArg := flags.Int("top", 0, "In size mode, only list the N largest resources of any type")
+	hashArg := flags.String("hash", "", "Print a digest of the body: md5, sha1 or sha256 (use with -method GET or -size)")
+	userAgentArg := flags.String("user-agent", cfg.UserAgent, "User-Agent to send, overrides -ua-preset")
+	randomUAArg := flags.Bool("random-ua", false, "Send a random realistic browser User-Agent with every request, printing the one used")
+	uaPresetArg := flags.String("ua-preset", "headview", "User-Agent preset: headview, chrome, firefox, safari, googlebot or curl")
+	diffArg := flags.String("diff", "", "Request this second URL as well and diff its status, headers, TLS details and timings against the first")
+	probeAllIPsArg := flags.Bool("probe-all-ips", false, "Request the target through every address its host resolves to and compare their timings")
+	compareMethodsArg := flags.Bool("compare-methods", false, "Request with HEAD and GET and report differences in status, headers and size")
+	checkHTTPSArg := flags.Bool("check-https-redirect", false, "Request the plain http:// form of the URL and check it redirects to https")
+	quietArg := flags.Bool("q", false, "Print a single STATUS TTFB TOTAL SIZE URL line per target")
+	statusLineArg := flags.Bool("status-line", false, "Print a one line JSON status per target on stderr, even when the request fails")
+	sitemapArg := flags.Bool("sitemap", false, "Treat the URL as a sitemap.xml (or .xml.gz) and request every page it lists")
+	rateArg := flags.Float64("rate", 0, "Limit requests to this many per second across all targets, redirects and retries, shared by the -concurrent-urls workers (0 is unlimited)")
+	watchArg := flags.Duration("watch", 0, "Re-run the request every interval until Ctrl-C, then summarize")
+	retriesArg := flags.Int("retries", 0, "Retry connection errors this many times with exponential backoff")
+	retryStatusArg := flags.Bool("retry-status", false, "Also retry 502, 503 and 504 responses (needs -retries)")
+	var repeatArg int
+	flags.IntVar(&repeatArg, "n", 1, "Repeat the request N times and report timing percentiles")
+	flags.IntVar(&repeatArg, "repeat", 1, "Alias for -n")
+	concurrentURLsArg := flags.Int("concurrent-urls", 1, "Request this many of the targets at once, reporting each as it completes")
+	var headerArgs headerFlags
+	flags.Var(&headerArgs, "H", "Add a request header \"Key: Value\" (repeatable)")
+	var cookieArgs cookieFlags
+	flags.Var(&cookieArgs, "cookie", "Send a cookie \"name=value\" to the target host (repeatable)")
 
 	// Parse the remaining command line arguments
-	flags.Parse(os.Args[2:])
+	flags.Parse(args)
+
+	if *verboseArg {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+
+	// config headers fill in whatever -H left unset
+	for key, value := range cfg.Headers {
+		if headerArgs.header.Get(key) == "" {
+			headerArgs.Set(key + ": " + value)
+		}
+	}
 
 	if *verArg {
-		fmt.Printf(aurora.Sprintf(aurora.Green("headview v%s\n"), aurora.Yellow(appVersion)))
+		fmt.Printf(au.Sprintf(au.Green("headview v%s\n"), au.Yellow(appVersion)))
+		return
+	}
+
+	if *inputFileArg != "" {
+		urls, err := readURLList(*inputFileArg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, au.Red("Error reading input file:"), au.Red(err))
+			os.Exit(1)
+		}
+		targets = append(targets, urls...)
+	}
+
+	// Check if URL is provided
+	if len(targets) == 0 {
+		fmt.Fprintln(out, "Please provide a URL as the first argument.")
 		return
 	}
+	if *diffArg != "" && len(targets) > 1 {
+		fmt.Fprintln(os.Stderr, au.Red("-diff compares a single URL, not a list"))
+		os.Exit(2)
+	}
+
+	method, err := validateMethod(*methodArg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, au.Red(err))
+		os.Exit(2)
+	}
+
+	methodSet := false
+	flags.Visit(func(f *flag.Flag) { methodSet = methodSet || f.Name == "method" })
+
+	var requestBody []byte
+	if *dataArg != "" {
+		if requestBody, err = readRequestBody(*dataArg); err != nil {
+			fmt.Fprintln(os.Stderr, au.Red(err))
+			os.Exit(2)
+		}
+		// a body with the default HEAD makes no sense, switch like curl does
+		if !methodSet {
+			method = http.MethodPost
+		}
+	}
+
+	// the refresh tag is in the body, which HEAD never gets
+	if *followMetaRefreshArg && method == http.MethodHead {
+		if methodSet {
+			fmt.Fprintln(os.Stderr, au.Red("-follow-meta-refresh needs a response body, use it with -method GET"))
+			os.Exit(2)
+		}
+		method = http.MethodGet
+	}
+
+	var legacyFormats []string
+	for name, set := range map[string]bool{formatJSON: *jsonArg, formatCSV: *csvArg, formatHAR: *harArg, formatPrometheus: *prometheusArg} {
+		if set {
+			legacyFormats = append(legacyFormats, name)
+		}
+	}
+	sort.Strings(legacyFormats)
+	formatSet := false
+	flags.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+	format, err := resolveOutputFormat(*formatArg, formatSet, legacyFormats)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, au.Red(err))
+		os.Exit(2)
+	}
+
+	if *ttfbGoodArg > *ttfbBadArg {
+		fmt.Fprintln(os.Stderr, au.Red("-ttfb-good must not be greater than -ttfb-bad"))
+		os.Exit(2)
+	}
+
+	if *maxRedirectsArg < 0 {
+		fmt.Fprintln(os.Stderr, au.Red("-max-redirects must be 0 or greater"))
+		os.Exit(2)
+	}
+
+	if repeatArg < 1 {
+		fmt.Fprintln(os.Stderr, au.Red("-n must be 1 or greater"))
+		os.Exit(2)
+	}
+
+	if *hashArg != "" {
+		if _, err := newBodyHash(*hashArg); err != nil {
+			fmt.Fprintln(os.Stderr, au.Red(err))
+			os.Exit(2)
+		}
+	}
+
+	if *randomUAArg {
+		uaSet := headerArgs.header.Get("User-Agent") != ""
+		flags.Visit(func(f *flag.Flag) { uaSet = uaSet || f.Name == "user-agent" || f.Name == "ua-preset" })
+		if uaSet {
+			fmt.Fprintln(os.Stderr, au.Red("-random-ua cannot be combined with -user-agent, -ua-preset or -H User-Agent"))
+			os.Exit(2)
+		}
+	}
+
+	userAgent := *userAgentArg
+	if userAgent == "" {
+		var err error
+		if userAgent, err = userAgentForPreset(*uaPresetArg); err != nil {
+			fmt.Fprintln(os.Stderr, au.Red(err))
+			os.Exit(2)
+		}
+	}
+
+	if *rateArg < 0 {
+		fmt.Fprintln(os.Stderr, au.Red("-rate must be 0 or greater"))
+		os.Exit(2)
+	}
+	var limiter *rate.Limiter
+	if *rateArg > 0 {
+		limiter = rate.NewLimiter(rate.Limit(*rateArg), 1)
+	}
+
+	if *topArg < 0 {
+		fmt.Fprintln(os.Stderr, au.Red("-top must be 0 or greater"))
+		os.Exit(2)
+	}
+	if *concurrencyArg < 1 {
+		fmt.Fprintln(os.Stderr, au.Red("-concurrency must be 1 or greater"))
+		os.Exit(2)
+	}
+	if *concurrentURLsArg < 1 {
+		fmt.Fprintln(os.Stderr, au.Red("-concurrent-urls must be 1 or greater"))
+		os.Exit(2)
+	}
+	// size and repeat runs print as they go and are not split per worker
+	if *concurrentURLsArg > 1 && (*sizeArg || repeatArg > 1) {
+		fmt.Fprintln(os.Stderr, au.Red("-concurrent-urls can't be combined with -size or -n"))
+		os.Exit(2)
+	}
+	// every iteration would add another document to the report
+	if *watchArg > 0 && (format == formatJSON || format == formatHAR) {
+		fmt.Fprintln(os.Stderr, au.Red("-watch can't be combined with -format "+format+", use -format jsonl"))
+		os.Exit(2)
+	}
+	if *retriesArg < 0 {
+		fmt.Fprintln(os.Stderr, au.Red("-retries must be 0 or greater"))
+		os.Exit(2)
+	}
+
+	if *userArg != "" && *bearerArg != "" {
+		fmt.Fprintln(os.Stderr, au.Red("-u and -bearer cannot be used together"))
+		os.Exit(2)
+	}
+
+	authUser, authPassword, err := parseBasicAuth(*userArg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, au.Red("Error reading password:"), au.Red(err))
+		os.Exit(1)
+	}
+
+	bearerToken, err := parseBearerToken(*bearerArg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, au.Red("Error reading bearer token:"), au.Red(err))
+		os.Exit(2)
+	}
+
+	network := "tcp"
+	switch {
+	case *ipv4Arg && *ipv6Arg:
+		fmt.Fprintln(os.Stderr, au.Red("-4 and -6 cannot be used together"))
+		os.Exit(2)
+	case *ipv4Arg:
+		network = "tcp4"
+	case *ipv6Arg:
+		network = "tcp6"
+	}
+
+	var proxyURL *url.URL
+	if *proxyArg != "" {
+		proxyURL, err = url.Parse(*proxyArg)
+		if err != nil || proxyURL.Host == "" {
+			fmt.Fprintln(os.Stderr, au.Red("Invalid -proxy URL:"), au.Red(*proxyArg))
+			os.Exit(2)
+		}
+	}
+
+	var certificates []tls.Certificate
+	if (*certArg == "") != (*keyArg == "") {
+		fmt.Fprintln(os.Stderr, au.Red("-cert and -key must be given together"))
+		os.Exit(2)
+	}
+	if *certArg != "" {
+		cert, err := tls.LoadX509KeyPair(*certArg, *keyArg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, au.Red("Error loading client certificate:"), au.Red(err))
+			os.Exit(1)
+		}
+		certificates = append(certificates, cert)
+	}
+
+	serverName := *sniArg
+	if serverName == "" && *hostArg != "" {
+		// the certificate is checked against the virtual host, not the address we dial
+		serverName, _, err = net.SplitHostPort(*hostArg)
+		if err != nil {
+			serverName = *hostArg
+		}
+	}
+
+	var minTLS, maxTLS uint16
+	for _, v := range []struct {
+		arg     string
+		version *uint16
+	}{{*minTLSArg, &minTLS}, {*maxTLSArg, &maxTLS}} {
+		if v.arg == "" {
+			continue
+		}
+		version, err := parseTLSVersion(v.arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, au.Red(err))
+			os.Exit(2)
+		}
+		*v.version = version
+	}
+	if minTLS != 0 && maxTLS != 0 && minTLS > maxTLS {
+		fmt.Fprintln(os.Stderr, au.Red("-min-tls must not be above -max-tls"))
+		os.Exit(2)
+	}
+
+	expectStatus, err := parseStatusPatterns(*expectStatusArg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, au.Red("Invalid -expect-status:"), au.Red(err))
+		os.Exit(2)
+	}
+
+	failOnStatus, err := parseStatusPatterns(*failOnStatusArg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, au.Red(err))
+		os.Exit(2)
+	}
+
+	headerFilter, err := parseHeaderFilter(*headerFilterArg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, au.Red("Invalid -header-filter:"), au.Red(err))
+		os.Exit(2)
+	}
+
+	var requestedRange *byteRange
+	if *rangeArg != "" {
+		r, err := parseByteRange(*rangeArg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, au.Red("Invalid -range:"), au.Red(err))
+			os.Exit(2)
+		}
+		if headerArgs.header.Get("Range") != "" {
+			fmt.Fprintln(os.Stderr, au.Red("-range and -H Range cannot be used together"))
+			os.Exit(2)
+		}
+		headerArgs.Set("Range: " + r.String())
+		requestedRange = &r
+	}
+
+	if *outputArg != "" {
+		f, err := os.Create(*outputArg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, au.Red("Error creating output file:"), au.Red(err))
+			os.Exit(2)
+		}
+		out = f
+		reportOut = f
+	}
+
+	// csv only has a writer for size mode, elsewhere it leaves the text output
+	if format != formatText && (format != formatCSV || *sizeArg) {
+		out = os.Stderr
+	}
+	setColor(useColor(out, *colorArg, *noColorArg))
+
+	clientOpts := clientOptions{
+		Timeout:       *timeoutArg,
+		Insecure:      *insecureArg,
+		Network:       network,
+		Proxy:         proxyURL,
+		Resolve:       resolveArgs,
+		Certificates:  certificates,
+		ServerName:    serverName,
+		MinTLSVersion: minTLS,
+		MaxTLSVersion: maxTLS,
+		HTTP3:         *http3Arg,
+		ForceHTTP1:    *forceHTTP1Arg,
+		NoKeepAlive:   *noKeepAliveArg,
+	}
+	client := createHTTPClient(clientOpts)
+	ctx := interruptContext()
+
+	opts := requestOptions{
+		Method:              method,
+		Headers:             headerArgs.header,
+		PrintHeaders:        *headersArg || len(headerFilter) > 0,
+		HeaderFilter:        headerFilter,
+		PrintRequestHeaders: *requestHeadersArg,
+		RandomUserAgent:     *randomUAArg,
+		Resolve:             resolveArgs,
+		FollowMetaRefresh:   *followMetaRefreshArg,
+		NoRedirect:          *noRedirectArg,
+		MaxRedirects:        *maxRedirectsArg,
+		Timeout:             *timeoutArg,
+		AuthUser:            authUser,
+		AuthPassword:        authPassword,
+		BearerToken:         bearerToken,
+		Host:                *hostArg,
+		UserAgent:           userAgent,
+		Body:                requestBody,
+		ContentType:         *contentTypeArg,
+		Retries:             *retriesArg,
+		RetryStatus:         *retryStatusArg,
+		Cookies:             cookieArgs,
+		Limiter:             limiter,
+		SaveBody:            *saveBodyArg,
+		Hash:                *hashArg,
+		MaxBody:             int64(maxBodyArg),
+		ctx:                 ctx,
+	}
+
+	run := runOptions{
+		Size: *sizeArg,
+		SizeOptions: sizeOptions{
+			CSSDepth:      *depthArg,
+			Accurate:      *accurateArg,
+			Hash:          *hashArg,
+			Selector:      *selectArg,
+			UserAgent:     userAgent,
+			RespectRobots: *respectRobotsArg,
+			MaxBody:       int64(maxBodyArg),
+			MinSize:       int64(minSizeArg),
+			Top:           *topArg,
+			Timing:        *resourceTimingArg,
+			Concurrency:   *concurrencyArg,
+			ctx:           ctx,
+		},
+		Format:         format,
+		Quiet:          *quietArg,
+		StatusLine:     *statusLineArg,
+		Repeat:         repeatArg,
+		ConcurrentURLs: *concurrentURLsArg,
+		Waterfall:      *waterfallArg,
+		TraceDNS:       *traceDNSArg,
+		OpenHAR:        *openArg,
+		FullTiming:     *fullTimingArg,
+		Thresholds:     timingThresholds{Good: *ttfbGoodArg, Bad: *ttfbBadArg},
+		Security:       *securityArg,
+		Cache:          *cacheArg,
+		Fingerprint:    *fingerprintArg,
+		Range:          requestedRange,
+
+		FailOnStatus: failOnStatus,
+
+		ExpectStatus:  expectStatus,
+		ExpectHeaders: expectHeaderArgs,
+		MaxTTFB:       *maxTTFBArg,
+	}
+
+	exitCode := 0
+	if *dnsOnlyArg {
+		exitCode = probeDNS(ctx, targets, network, resolveArgs, *timeoutArg)
+	} else if *diffArg != "" {
+		exitCode = diffTargets(client, targets[0], addDefaultProtocol(*diffArg), opts)
+	} else if *probeAllIPsArg {
+		exitCode = probeAllIPs(targets, clientOpts, opts)
+	} else if *compareMethodsArg {
+		exitCode = compareMethods(client, targets, opts)
+	} else if *checkHTTPSArg {
+		exitCode = checkHTTPSRedirects(client, targets, opts)
+	} else if *sitemapArg {
+		exitCode = crawlSitemaps(client, targets, opts, *concurrencyArg)
+	} else if *watchArg > 0 {
+		watchTargets(client, targets, opts, run, *watchArg)
+	} else {
+		exitCode = runTargets(client, targets, opts, run)
+	}
+
+	// os.Exit skips deferred calls, close the -o file first
+	if f, ok := reportOut.(*os.File); ok && f != os.Stdout {
+		f.Close()
+	}
+	os.Exit(exitCode)
+}
+
+// runTargets runs each target once and returns the exit code the threshold
+// checks call for. With run.ConcurrentURLs above one the targets are
+// requested that many at a time and reported as they complete
+func runTargets(client *http.Client, targets []string, opts requestOptions, run runOptions) int {
+	var failed int
+	var metrics []prometheusTarget
+	// one document per target would leave a file no JSON or HAR reader takes
+	var collected *runReport
+	if len(targets) > 1 && (run.Format == formatJSON || run.Format == formatHAR) {
+		collected = &runReport{format: run.Format}
+	}
+	// timeStats is reset per target, keep every connection for the reuse stats
+	var connections []timmingsCommon
+	exitCode := 0
+	var ran int
+
+	// finish reports a target whose results are in timeStats and responses
+	finish := func(urlArg string, err error, elapsed time.Duration) {
+		ran++
+		connections = append(connections, timeStats.CommonTimmings...)
+		if err != nil {
+			failed++
+		}
+		var final *responseInfo
+		if len(responses) > 0 {
+			final = &responses[len(responses)-1]
+		}
+		if run.Quiet {
+			printSummaryLine(urlArg, final, &timeStats)
+		}
+		if run.StatusLine {
+			// a failed request never sets the total, fall back to wall time
+			total := timeStats.TotalRequestTime
+			if total == 0 {
+				total = elapsed
+			}
+			if lineErr := writeStatusLine(os.Stderr, urlArg, final, total, err); lineErr != nil {
+				fmt.Fprintln(out, au.Red("Error writing status line:"), au.Red(lineErr))
+			}
+		}
+		if run.Format == formatPrometheus {
+			metric := prometheusTarget{URL: urlArg, Timings: timeStats}
+			if len(responses) > 0 {
+				metric.Status = responses[len(responses)-1].Response.StatusCode
+			}
+			metrics = append(metrics, metric)
+		}
+		for _, reason := range checkThresholds(run) {
+			fmt.Fprintln(os.Stderr, au.Red("FAIL:"), reason)
+			exitCode = 1
+		}
+		if !checkExpectations(run) {
+			exitCode = 1
+		}
+	}
+
+	if run.ConcurrentURLs > 1 {
+		for result := range probeTargets(client, targets, opts, run.ConcurrentURLs) {
+			timeStats, responses = result.State.timeStats, result.State.responses
+			// -q keeps only the summary line of each target
+			stdout := out
+			if run.Quiet {
+				out = io.Discard
+			}
+			out.Write(result.Output.Bytes())
+			reportTarget(result.URL, nil, result.Err, run, collected)
+			out = stdout
+			finish(result.URL, result.Err, result.Elapsed)
+		}
+		if ran < len(targets) {
+			fmt.Fprintln(os.Stderr, au.Yellow("Interrupted, remaining targets skipped"))
+			exitCode = exitInterrupted
+		}
+	} else {
+		for _, urlArg := range targets {
+			// -q keeps only the summary line of each target
+			stdout := out
+			if run.Quiet {
+				out = io.Discard
+			}
+			started := time.Now()
+			err := runTarget(client, urlArg, opts, run, collected)
+			elapsed := time.Since(started)
+			out = stdout
+			finish(urlArg, err, elapsed)
+			if opts.context().Err() != nil {
+				fmt.Fprintln(os.Stderr, au.Yellow("Interrupted, remaining targets skipped"))
+				exitCode = exitInterrupted
+				break
+			}
+		}
+	}
+
+	if len(targets) > 1 && !run.Quiet {
+		fmt.Fprintln(out)
+		summary := []any{au.Green("Succeeded:"), au.Blue(ran - failed), au.Green("Failed:"), au.Red(failed)}
+		if ran < len(targets) {
+			summary = append(summary, au.Green("Skipped:"), au.Yellow(len(targets)-ran))
+		}
+		fmt.Fprintln(out, summary...)
+		printConnectionReuseStats(connections)
+	}
+
+	// every sample of a metric family has to sit under one HELP/TYPE header
+	if run.Format == formatPrometheus {
+		if err := writePrometheus(reportOut, metrics); err != nil {
+			fmt.Fprintln(out, au.Red("Error writing metrics:"), au.Red(err))
+			return 1
+		}
+	}
+	if collected != nil {
+		if err := collected.write(reportOut); err != nil {
+			fmt.Fprintln(out, au.Red("Error writing "+run.Format+" report:"), au.Red(err))
+			return 1
+		}
+	}
+	return exitCode
+}
+
+// targetResult is one target requested by a probeTargets worker, with the
+// narration the pipeline would have printed held back in Output
+type targetResult struct {
+	URL     string
+	State   requestState
+	Output  *bytes.Buffer
+	Err     error
+	Elapsed time.Duration
+}
+
+// probeTargets requests the targets workers at a time and sends each result
+// as soon as it is in, so reports are written in completion order rather
+// than after the slowest target. Every request still waits its turn on
+// opts.Limiter, so -rate caps the total across the workers. After an
+// interrupt no new targets are started and the channel is closed once the
+// ones in flight are done
+func probeTargets(client *http.Client, targets []string, opts requestOptions, workers int) <-chan targetResult {
+	jobs := make(chan string)
+	results := make(chan targetResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for urlArg := range jobs {
+				result := targetResult{URL: urlArg, Output: new(bytes.Buffer)}
+				result.State.out = result.Output
+				started := time.Now()
+				result.Err = runRequest(client, urlArg, opts, &result.State)
+				result.Elapsed = time.Since(started)
+				results <- result
+			}
+		}()
+	}
+
+	go func() {
+	feed:
+		for _, urlArg := range targets {
+			select {
+			case jobs <- urlArg:
+			case <-opts.context().Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// runTarget performs the full request or size flow for a single URL, a
+// non-nil collected takes its json or har report instead of reportOut
+func runTarget(client *http.Client, urlArg string, opts requestOptions, run runOptions, collected *runReport) error {
+	timeStats = timmings{}
+	responses = nil
+
+	var err error
+	var resources resourceMap
+	switch {
+	case run.Size:
+		resources, err = performGetSize(client, urlArg, run.SizeOptions)
+	case run.Repeat > 1:
+		var samples []timmingsCommon
+		samples, err = performGetRequestRepeated(client, urlArg, opts, run.Repeat)
+		if len(samples) > 0 {
+			printTimingPercentiles(samples)
+		}
+	default:
+		err = performGetRequest(client, urlArg, opts)
+	}
+	reportTarget(urlArg, resources, err, run, collected)
+	return err
+}
 
-	client := createHTTPClient()
+// reportTarget writes the text analysis and the -format report of a target
+// from timeStats and responses, or from resources in size mode
+func reportTarget(urlArg string, resources resourceMap, err error, run runOptions, collected *runReport) {
+	text := formatters[formatText](run)
+	// nil for text, for prometheus and collected reports, which runTargets
+	// writes for the whole run, and for jsonl, whose line also carries the error
+	var report formatter
+	if build, ok := formatters[run.Format]; ok && run.Format != formatText && collected == nil {
+		report = build(run)
+	}
 
-	if *sizeArg {
-		performGetSize(client, urlArg)
+	var textErr, reportErr error
+	if run.Size {
+		if resources != nil {
+			// the CSV rows replace the listing rather than accompany it
+			if run.Format != formatCSV {
+				textErr = text.FormatSizes(out, resources)
+			}
+			if report != nil {
+				reportErr = report.FormatSizes(reportOut, resources)
+			}
+		}
 	} else {
-		performGetRequest(client, urlArg, *headersArg)
-		//print time stats
-		printTimmingStats()
+		if run.Repeat <= 1 {
+			textErr = text.FormatResponse(out, responses, &timeStats)
+		}
+		if report != nil {
+			reportErr = report.FormatResponse(reportOut, responses, &timeStats)
+		}
+	}
+	infos := responses
+	if run.Size {
+		infos = nil
+	}
+	if run.Format == formatJSONL {
+		reportErr = writeJSONLine(reportOut, urlArg, infos, &timeStats, resources, err)
+	}
+	if collected != nil {
+		reportErr = collected.add(urlArg, infos, &timeStats, resources, err)
+	}
+
+	if textErr != nil {
+		fmt.Fprintln(os.Stderr, au.Red("Error writing output:"), au.Red(textErr))
+		os.Exit(1)
+	}
+	if reportErr != nil {
+		fmt.Fprintln(out, au.Red("Error writing "+run.Format+" report:"), au.Red(reportErr))
+		os.Exit(1)
 	}
 
+	if run.OpenHAR && len(responses) > 0 {
+		har, harErr := buildHAR(responses, &timeStats)
+		if harErr == nil {
+			harErr = openHAR(har)
+		}
+		if harErr != nil {
+			fmt.Fprintln(out, au.Red("Error opening HAR:"), au.Red(harErr))
+		}
+	}
 }
 
-func printTimmingStats() {
-	fmt.Println(aurora.Green(("Connection")))
+// printTimmingStats prints the connection and request timings of stats,
+// hops is the number of responses they were combined from. Unless
+// fullTiming is set, phases with nothing to report are left out
+func printTimmingStats(stats *timmings, hops int, fullTiming bool, thresholds timingThresholds) {
+	fmt.Fprintln(out, au.Green(("Connection")))
 
 	//Connection Timmings
-	if len(timeStats.CommonTimmings) > 1 {
+	if len(stats.CommonTimmings) > 1 {
 		var multireqgraph [][]float64
 
-		for _, t := range timeStats.CommonTimmings {
-			fmt.Printf("%20s %-10s\n", aurora.Yellow("DNS lookup"), formatDuration(t.DNSLookupTime))
-			fmt.Printf("%20s %-10s\n", aurora.Yellow("TCP connection"), formatDuration(t.TCPConnTime))
-			fmt.Printf("%20s %-10s\n", aurora.Yellow("TLS handshake"), formatDuration(t.TLSHandshakeTime))
-			fmt.Printf("%20s %-10s\n", aurora.Yellow("Time To First Byte"), formatDuration(t.TTFB))
-			fmt.Println()
+		printTimingTable(stats)
+
+		for i, t := range stats.CommonTimmings {
+			fmt.Fprintln(out, au.Green(fmt.Sprintf("Connection #%d", i+1)))
+			printConnectionDetails(t)
+			fmt.Fprintln(out)
 			multireqgraph = append(multireqgraph, []float64{t.DNSLookupTime.Seconds(), t.TCPConnTime.Seconds(), t.TLSHandshakeTime.Seconds(), t.TTFB.Seconds()})
 		}
 
-		graph := asciigraph.PlotMany(multireqgraph, asciigraph.Height(10), asciigraph.SeriesColors(asciigraph.White, asciigraph.Blue))
-		fmt.Println(graph)
-		fmt.Println()
+		graphOptions := []asciigraph.Option{asciigraph.Height(10)}
+		if colorEnabled {
+			var colors []asciigraph.AnsiColor
+			for i := range multireqgraph {
+				colors = append(colors, graphSeriesColors[i%len(graphSeriesColors)].graph)
+			}
+			graphOptions = append(graphOptions, asciigraph.SeriesColors(colors...))
+		}
+		graph := asciigraph.PlotMany(multireqgraph, graphOptions...)
+		fmt.Fprintln(out, graph)
+		printGraphLegend(connectionPhaseLabels)
+		printSeriesLegend(len(multireqgraph))
+		fmt.Fprintln(out)
 	} else {
-		reqgraph := asciigraph.Plot(timeStats.ExtractConnectionDurations())
+		reqgraph := asciigraph.Plot(stats.ExtractConnectionDurations())
 
-		fmt.Printf("%20s %-10s\n", aurora.Yellow("DNS lookup"), formatDuration(timeStats.CommonTimmings[0].DNSLookupTime))
-		fmt.Printf("%20s %-10s\n", aurora.Yellow("TCP connection"), formatDuration(timeStats.CommonTimmings[0].TCPConnTime))
-		fmt.Printf("%20s %-10s\n", aurora.Yellow("TLS handshake"), formatDuration(timeStats.CommonTimmings[0].TLSHandshakeTime))
-		fmt.Printf("%20s %-10s\n", aurora.Yellow("TTFB"), formatDuration(timeStats.CommonTimmings[0].TTFB))
+		t := stats.CommonTimmings[0]
+		// compact output drops the phases a connection never went through
+		compact := !fullTiming && !t.PhasesUnavailable
+		if compact && t.ConnectionReused {
+			fmt.Fprintf(out, "%20s %s\n", au.Yellow("Connection"), au.Blue("reused, DNS/TCP/TLS skipped"))
+		} else {
+			// a skipped lookup is shown so it does not read as a missing phase
+			if !compact || t.DNSLookupTime > 0 || t.DNSSkipped {
+				fmt.Fprintf(out, "%20s %-10s%s\n", au.Yellow("DNS lookup"), formatDNSDuration(t), phaseShare(t, t.DNSLookupTime))
+			}
+			if !compact || t.TCPConnTime > 0 {
+				fmt.Fprintf(out, "%20s %-10s%s\n", au.Yellow("TCP connection"), formatTCPDuration(t), phaseShare(t, t.TCPConnTime))
+			}
+			if !compact || t.TLSHandshakeTime > 0 {
+				fmt.Fprintf(out, "%20s %-10s%s\n", au.Yellow("TLS handshake"), formatPhaseDuration(t, t.TLSHandshakeTime), phaseShare(t, t.TLSHandshakeTime))
+			}
+		}
+		fmt.Fprintf(out, "%20s %s%s\n", au.Yellow("TTFB"), colorizeDuration(t.TTFB, thresholds), phaseShare(t, t.TTFB))
+		// part of TTFB, what is left of it went to writing the request
+		if t.WaitingForServerTime > 0 {
+			fmt.Fprintf(out, "%20s %-10s%s\n", au.Yellow("Wait (in TTFB)"), formatDuration(t.WaitingForServerTime), phaseShare(t, t.WaitingForServerTime))
+		}
+		printConnectionDetails(stats.CommonTimmings[0])
 
-		fmt.Println(reqgraph)
-		fmt.Println()
+		fmt.Fprintln(out, reqgraph)
+		printGraphLegend(connectionPhaseLabels)
+		fmt.Fprintln(out)
 	}
 
 	//Request Timmings
-	fmt.Println(aurora.Green(("Request")))
-	reqgraph := asciigraph.Plot(timeStats.ExtractDurations())
+	if hops > 1 {
+		fmt.Fprintln(out, au.Green(fmt.Sprintf("Request (%d hops combined)", hops)))
+	} else {
+		fmt.Fprintln(out, au.Green(("Request")))
+	}
+	reqgraph := asciigraph.Plot(stats.ExtractDurations())
+
+	fmt.Fprintf(out, "%20s %-10s\n", au.Yellow("Request sending"), formatDuration(stats.RequestSendingTime))
+	fmt.Fprintf(out, "%20s %-10s\n", au.Yellow("Server processing"), formatDuration(stats.ServerProcessingTime))
+	fmt.Fprintf(out, "%20s %-10s\n", au.Yellow("Content transfer"), formatDuration(stats.ContentTransferTime))
+
+	fmt.Fprintln(out, reqgraph)
+	printGraphLegend(requestPhaseLabels)
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Total request"), colorizeDuration(stats.TotalRequestTime, thresholds))
+}
+
+// asciigraph cannot label categories, so the x positions are listed under each plot
+var (
+	connectionPhaseLabels = []string{"DNS", "TCP", "TLS", "TTFB"}
+	requestPhaseLabels    = []string{"Sending", "Processing", "Transfer"}
+)
+
+// graphSeriesColors pairs each multi-connection series color with the aurora
+// color used for it in the legend
+var graphSeriesColors = []struct {
+	graph  asciigraph.AnsiColor
+	legend aurora.Color
+}{
+	{asciigraph.White, aurora.WhiteFg},
+	{asciigraph.Blue, aurora.BlueFg},
+	{asciigraph.Green, aurora.GreenFg},
+	{asciigraph.Yellow, aurora.YellowFg},
+	{asciigraph.Magenta, aurora.MagentaFg},
+	{asciigraph.Cyan, aurora.CyanFg},
+}
+
+func printGraphLegend(labels []string) {
+	parts := make([]string, len(labels))
+	for i, label := range labels {
+		parts[i] = fmt.Sprintf("%d %s", i, label)
+	}
+	fmt.Fprintln(out, au.Yellow("x axis:"), strings.Join(parts, ", "))
+}
+
+func printSeriesLegend(series int) {
+	parts := make([]string, series)
+	for i := range parts {
+		parts[i] = au.Colorize(fmt.Sprintf("Connection #%d", i+1), graphSeriesColors[i%len(graphSeriesColors)].legend).String()
+	}
+	fmt.Fprintln(out, au.Yellow("Series:"), strings.Join(parts, ", "))
+}
 
-	fmt.Printf("%20s %-10s\n", aurora.Yellow("Request sending"), formatDuration(timeStats.RequestSendingTime))
-	fmt.Printf("%20s %-10s\n", aurora.Yellow("Server processing"), formatDuration(timeStats.ServerProcessingTime))
-	fmt.Printf("%20s %-10s\n", aurora.Yellow("Content transfer"), formatDuration(timeStats.ContentTransferTime))
+func printConnectionDetails(t timmingsCommon) {
+	if t.Protocol != "" {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Protocol"), au.Blue(t.Protocol))
+	}
+	if t.RemoteAddr != "" {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Remote address"), au.Blue(t.RemoteAddr))
+	}
+	if len(t.ResolvedIPs) > 0 {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Resolved IPs"), au.Blue(strings.Join(t.ResolvedIPs, ", ")))
+	}
+	if len(t.AdvertisedProtocols) > 0 {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Advertises"), au.Blue(strings.Join(t.AdvertisedProtocols, ", ")))
+	}
+	printTLSInfo(t)
+	printHTTP2Details(t)
+}
 
-	fmt.Println(reqgraph)
+// printDNSTrace expands the DNS phase of every connection that resolved a name
+func printDNSTrace(timings []timmingsCommon) {
+	fmt.Fprintln(out, au.Green("DNS"))
+	for i, t := range timings {
+		if len(timings) > 1 {
+			fmt.Fprintln(out, au.Green(fmt.Sprintf("Connection #%d", i+1)))
+		}
+		if t.DNSHost == "" {
+			fmt.Fprintf(out, "%20s %s\n", au.Yellow("Lookup"), au.Blue(formatDNSDuration(t)))
+			continue
+		}
 
-	fmt.Println()
-	fmt.Printf("%20s %-10s\n", aurora.Yellow("Total request"), formatDuration(timeStats.TotalRequestTime))
+		coalesced := "no"
+		if t.DNSCoalesced {
+			coalesced = "yes, shared an in-flight lookup"
+		}
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Host"), au.Blue(t.DNSHost))
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Queued"), au.Blue(formatDuration(t.DNSQueueTime)))
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Lookup"), au.Blue(formatDuration(t.DNSLookupTime)))
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Coalesced"), au.Blue(coalesced))
+		addrs := "none"
+		if len(t.ResolvedIPs) > 0 {
+			addrs = strings.Join(t.ResolvedIPs, ", ")
+		}
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Addresses"), au.Blue(addrs))
+	}
+	fmt.Fprintln(out)
 }
 
 func (t *timmings) ExtractConnectionDurations() []float64 {
@@ -118,88 +990,508 @@ func (t *timmings) ExtractDurations() []float64 {
 	durations = append(durations,
 		t.RequestSendingTime.Seconds(),
 		t.ServerProcessingTime.Seconds(),
-		// t.TotalRequestTime.Seconds(),
 		t.ContentTransferTime.Seconds(),
 	)
 	return durations
 }
 
-// StringsToFloats converts a slice of strings to a slice of float64.
-// For each string that can't be converted, it appends 0 to the float slice.
-// Only the last error encountered is returned (if any).
-func stringsToFloats(s []string) ([]float64, error) {
-	var floats []float64
-	var lastError error
+func setColor(enabled bool) {
+	colorEnabled = enabled
+	au = aurora.NewAurora(enabled)
+}
 
-	for _, str := range s {
-		val, err := strconv.ParseFloat(str, 64)
-		if err != nil {
-			lastError = fmt.Errorf("failed to convert string %q to float: %v", str, err)
-			val = 0.0
+// useColor is the one place that decides on colors: -no-color wins, -color
+// forces them, and otherwise only a terminal that can render ANSI escapes
+// gets them. Consoles that need it have escape processing switched on here
+func useColor(w io.Writer, force, disable bool) bool {
+	if disable {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if force {
+		if ok {
+			enableANSI(f)
 		}
-		floats = append(floats, val)
+		return true
 	}
+	return isTerminal(w) && enableANSI(f)
+}
 
-	return floats, lastError
+// isTerminal reports whether w is a terminal, anything else gets plain text
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+func validateMethod(method string) (string, error) {
+	method = strings.ToUpper(method)
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method, nil
+	}
+	return "", fmt.Errorf("unknown HTTP method %q", method)
 }
 
 func addDefaultProtocol(s string) string {
+	if target, ok := unixSocketURL(s); ok {
+		return target
+	}
 	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
-		return "https://" + s
+		s = "https://" + s
 	}
-	return s
+	return toASCIIURL(s)
 }
 
-func createHTTPClient() *http.Client {
-	return &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
+func createHTTPClient(opts clientOptions) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   opts.Timeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.Insecure,
+		Certificates:       opts.Certificates,
+		ServerName:         opts.ServerName,
+		MinVersion:         opts.MinTLSVersion,
+		MaxVersion:         opts.MaxTLSVersion,
+	}
+	if len(opts.Certificates) == 0 {
+		tlsConfig.GetClientCertificate = noteCertificateRequest
+	}
+
+	// cookiejar.New only fails on a broken public suffix list, and we pass none
+	jar, _ := cookiejar.New(nil)
+
+	if opts.HTTP3 {
+		return &http.Client{
+			Timeout: opts.Timeout,
+			Transport: &http3.RoundTripper{
+				TLSClientConfig:    tlsConfig,
+				DisableCompression: true,
 			},
+			Jar: jar,
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy: proxyFunc(opts.Proxy),
+		// the network is forced to tcp4 or tcp6 by -4 and -6
+		DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+			if socket, ok := unixSocketPath(addr); ok {
+				return dialer.DialContext(ctx, "unix", socket)
+			}
+			return dialer.DialContext(ctx, opts.Network, resolveOverride(opts.Resolve, addr))
 		},
+		DisableCompression: true,
+		TLSClientConfig:    tlsConfig,
+		// a custom dialer turns off h2 unless asked for explicitly
+		ForceAttemptHTTP2: !opts.ForceHTTP1,
+		DisableKeepAlives: opts.NoKeepAlive,
+	}
+	if opts.ForceHTTP1 {
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	}
+
+	// socks proxies sit below the transport, so they replace the dialer
+	if opts.Proxy != nil && strings.HasPrefix(opts.Proxy.Scheme, "socks5") {
+		socksDialer, err := proxy.FromURL(opts.Proxy, dialer)
+		if err == nil {
+			if contextDialer, ok := socksDialer.(proxy.ContextDialer); ok {
+				transport.Proxy = nil
+				transport.DialContext = contextDialer.DialContext
+			}
+		} else {
+			fmt.Fprintln(out, au.Red("Error configuring socks proxy:"), au.Red(err))
+		}
+	}
+
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+		Jar:       jar,
 	}
 }
 
-func performGetRequest(client *http.Client, urlArg string, headersArg bool) {
-	req, err := http.NewRequest("HEAD", urlArg, nil)
+// resolveOverride swaps the host for a -resolve pinned IP, the request keeps
+// its Host header and SNI since only the dialed address changes
+func resolveOverride(resolve map[string]string, addr string) string {
+	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
-		fmt.Println(aurora.Green("Error creating request:"), aurora.Blue(err))
-		return
+		return addr
+	}
+	if ip, ok := resolve[strings.ToLower(host)]; ok {
+		return net.JoinHostPort(ip, port)
 	}
+	return addr
+}
 
-	fmt.Println(aurora.Magenta("Requesting URL:"), aurora.Cyan(urlArg))
+// proxyFunc uses the explicit proxy when given, the environment otherwise.
+// The environment is read when the client is built, http.ProxyFromEnvironment
+// would keep whatever it saw first for the life of the process
+func proxyFunc(proxyURL *url.URL) func(*http.Request) (*url.URL, error) {
+	proxied := http.ProxyURL(proxyURL)
+	if proxyURL == nil {
+		fromEnv := httpproxy.FromEnvironment().ProxyFunc()
+		proxied = func(req *http.Request) (*url.URL, error) {
+			return fromEnv(req.URL)
+		}
+	}
+	// unix socket targets are always dialed directly
+	return func(req *http.Request) (*url.URL, error) {
+		if _, ok := unixSockets[req.URL.Hostname()]; ok {
+			return nil, nil
+		}
+		return proxied(req)
+	}
+}
+
+// performGetRequest runs the request pipeline into the package level
+// timeStats and responses, which the sequential modes read afterwards
+func performGetRequest(client *http.Client, urlArg string, opts requestOptions) error {
+	st := &requestState{out: out}
+	err := runRequest(client, urlArg, opts, st)
+	timeStats, responses = st.timeStats, st.responses
+	return err
+}
 
-	// Disable auto-redirect
-	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+// runRequest is the request pipeline collecting into and narrating to st.
+// It touches no package state, so requests on states of their own can run
+// side by side
+func runRequest(client *http.Client, urlArg string, opts requestOptions, st *requestState) error {
+	// redirects are followed hop by hop, on a copy so the caller's client,
+	// which size mode lets follow them itself, is left alone
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		return http.ErrUseLastResponse
 	}
+	client = &noRedirect
+
+	// credentials and the Host override only apply to the host the user asked for
+	if u, err := url.Parse(urlArg); err == nil {
+		opts.originHost = u.Host
+		// the jar keeps them host-only, so redirects elsewhere do not carry them
+		if client.Jar != nil && len(opts.Cookies) > 0 {
+			client.Jar.SetCookies(u, opts.Cookies)
+		}
+	}
+	// picked once per request so every hop of a redirect chain sends the same one
+	if opts.RandomUserAgent {
+		opts.UserAgent = randomUserAgent()
+		fmt.Fprintln(st.out, au.Magenta("Random User-Agent:"), au.Blue(opts.UserAgent))
+	}
+	st.userAgent = opts.UserAgent
+	return performGetRequestRecursive(client, urlArg, opts, st, 0)
+}
+
+func performGetRequestRecursive(client *http.Client, urlArg string, opts requestOptions, st *requestState, depth int) error {
+	var body io.Reader
+	if opts.Body != nil {
+		body = bytes.NewReader(opts.Body)
+	}
+	req, err := http.NewRequest(opts.Method, urlArg, body)
+	if err != nil {
+		fmt.Fprintln(st.out, au.Green("Error creating request:"), au.Blue(err))
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", opts.UserAgent)
+	if opts.Body != nil {
+		req.Header.Set("Content-Type", opts.ContentType)
+	}
+
+	// user supplied headers replace any defaults, including User-Agent
+	for key, values := range opts.Headers {
+		req.Header[key] = values
+	}
+
+	setAcceptEncoding(req)
+
+	// redirects to another host get their own Host header
+	if opts.Host != "" && req.URL.Host == opts.originHost {
+		req.Host = opts.Host
+	}
 
-	start := time.Now()
-	trace := createHTTPTrace()
-	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	if opts.AuthUser != "" && req.URL.Host == opts.originHost {
+		req.SetBasicAuth(opts.AuthUser, opts.AuthPassword)
+	}
+	if opts.BearerToken != "" && req.URL.Host == opts.originHost {
+		req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+	}
+
+	fmt.Fprintln(st.out, au.Magenta("Requesting URL:"), au.Cyan(displayURL(urlArg)))
+	logger.Debug("requesting", "method", req.Method, "url", urlArg, "redirect", depth)
+
+	// a -rate wait is not part of the request, so it happens before the
+	// timeout starts counting and is only cut short by an interrupt
+	if opts.Limiter != nil {
+		if err := opts.Limiter.Wait(opts.context()); err != nil {
+			fmt.Fprintln(st.out, au.Red("Error waiting for rate limit:"), au.Red(err))
+			return fmt.Errorf("waiting for rate limit: %w", err)
+		}
+	}
+
+	// same deadline as the client timeout so neither cuts the other short
+	ctx, cancel := context.WithTimeout(opts.context(), opts.Timeout)
+	defer cancel()
+	ctx = withCertificateRequestFlag(ctx)
 
-	requestSendingStart := time.Now()
-	resp, err := client.Do(req)
-	requestSendingTime := time.Since(requestSendingStart)
+	sentHeaders := make(http.Header)
+	trace := createHTTPTrace(st.out, sentHeaders, opts.Resolve, func(t timmingsCommon) {
+		st.timeStats.CommonTimmings = append(st.timeStats.CommonTimmings, t)
+	})
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	traced := len(st.timeStats.CommonTimmings)
+	resp, start, attempts, err := doWithRetry(client, req, opts, st)
+	requestSendingTime := time.Since(start)
 
 	if err != nil {
-		fmt.Println(aurora.Red("Error sending request:"), aurora.Red(err))
-		return
+		if isCertificateError(err) {
+			fmt.Fprintln(st.out, au.Red("TLS certificate verification failed:"), au.Red(describeCertificateError(err)))
+			fmt.Fprintln(st.out, au.Yellow("Use -insecure to skip certificate verification"))
+			return fmt.Errorf("verifying certificate: %w", err)
+		}
+		if isTLSVersionMismatch(err) {
+			fmt.Fprintln(st.out, au.Red("TLS handshake failed, the server accepts none of the offered TLS versions:"), au.Red(err))
+			fmt.Fprintln(st.out, au.Yellow("Adjust -min-tls and -max-tls to probe which versions it supports"))
+			return fmt.Errorf("TLS version not supported: %w", err)
+		}
+		if isTLSRecordHeaderError(err) {
+			fmt.Fprintln(st.out, au.Red("TLS handshake failed, the server did not answer with TLS:"), au.Red(err))
+			fmt.Fprintln(st.out, au.Yellow("The port may serve plain HTTP, try the http:// URL"))
+			return fmt.Errorf("TLS handshake: %w", err)
+		}
+		if isClientCertificateRequired(req.Context(), err) {
+			fmt.Fprintln(st.out, au.Red("TLS handshake failed, the server requires a client certificate:"), au.Red(err))
+			fmt.Fprintln(st.out, au.Yellow("Use -cert and -key to provide one"))
+			return fmt.Errorf("client certificate required: %w", err)
+		}
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) {
+			fmt.Fprintln(st.out, au.Red("DNS resolution failed for"), au.Red(dnsErr.Name+":"), au.Red(dnsErr.Err))
+			return fmt.Errorf("resolving host: %w", err)
+		}
+		fmt.Fprintln(st.out, au.Red("Error sending request:"), au.Red(err))
+		return fmt.Errorf("sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// QUIC never reports written headers, the request's own are the best we have
+	if len(sentHeaders) == 0 {
+		sentHeaders = req.Header.Clone()
+	}
+	if opts.PrintRequestHeaders {
+		printRequestHeaders(st.out, sentHeaders)
+	}
+
+	if attempts > 1 {
+		fmt.Fprintln(st.out, au.Green("Attempts:"), au.Blue(attempts))
+	}
+
+	// the client stores these in its jar, including on 3xx responses, so the
+	// next hop of a manually followed redirect sends them back
+	for _, cookie := range resp.Cookies() {
+		fmt.Fprintln(st.out, au.Green("Cookie set:"), au.Blue(cookie.String()))
+	}
+
+	// QUIC round trips never reach the httptrace hooks, keep what we can measure
+	if len(st.timeStats.CommonTimmings) == traced {
+		st.timeStats.CommonTimmings = append(st.timeStats.CommonTimmings, timmingsCommon{
+			TTFB:              requestSendingTime,
+			PhasesUnavailable: true,
+		})
+	}
+
+	// headers only exist once the response is in, so annotate the connection that carried it
+	conn := &st.timeStats.CommonTimmings[len(st.timeStats.CommonTimmings)-1]
+	conn.AdvertisedProtocols = parseAltSvc(resp.Header.Get("Alt-Svc"))
+	conn.Protocol = resp.Proto
+	conn.ProtoMajor, conn.ProtoMinor = resp.ProtoMajor, resp.ProtoMinor
+	if resp.ProtoMajor == 3 {
+		conn.Protocol = "HTTP/3"
+	}
+
 	// Check if a redirect response is received
-	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+	isRedirect := resp.StatusCode >= 300 && resp.StatusCode < 400 && !opts.NoRedirect
+	if isRedirect && depth >= opts.MaxRedirects {
+		fmt.Fprintln(st.out, au.Yellow("Maximum redirects reached, not following"))
+		isRedirect = false
+	}
+
+	if isRedirect {
 		location, err := resp.Location()
 		if err != nil {
-			fmt.Println(aurora.Red("Error reading redirect location:"), aurora.Red(err))
-			return
+			fmt.Fprintln(st.out, au.Red("Error reading redirect location:"), au.Red(err))
+			return fmt.Errorf("reading redirect location: %w", err)
 		}
-		fmt.Println(aurora.Magenta("Redirecting to:"), aurora.Cyan(location.String()))
-		performGetRequest(client, location.String(), headersArg)
-	} else {
-		printResponse(start, resp, requestSendingTime, headersArg)
+		st.responses = append(st.responses, responseInfo{
+			URL:        urlArg,
+			Response:   resp,
+			StatusCode: resp.StatusCode,
+			StatusText: statusText(resp),
+			HeaderSize: headerSize(resp),
+			Started:    start,
+			Attempts:   attempts,
+
+			RequestHeaders: sentHeaders,
+		})
+		addHopTimings(st, start, requestSendingTime)
+		fmt.Fprintln(st.out, au.Magenta("Redirecting to:"), au.Cyan(displayURL(location.String())))
+		logger.Debug("following redirect", "status", resp.StatusCode, "from", urlArg, "to", location.String())
+		return performGetRequestRecursive(client, location.String(), redirectRequestOptions(opts, resp.StatusCode), st, depth+1)
+	}
+
+	decoded, err := printResponse(st, start, urlArg, resp, requestSendingTime, opts)
+	if err != nil {
+		return err
+	}
+	st.responses[len(st.responses)-1].Attempts = attempts
+	st.responses[len(st.responses)-1].RequestHeaders = sentHeaders
+
+	if opts.FollowMetaRefresh && resp.StatusCode == http.StatusOK && isHTML(resp.Header.Get("Content-Type"), decoded) {
+		if target := metaRefreshTarget(decoded); target != "" {
+			return followMetaRefresh(client, req.URL, target, opts, st, depth)
+		}
+	}
+	return nil
+}
+
+// followMetaRefresh continues the chain at a meta refresh target under the
+// same -no-redirect switch and depth limit as 3xx redirects, stopping when it points back at a URL
+// the chain already visited
+func followMetaRefresh(client *http.Client, base *url.URL, target string, opts requestOptions, st *requestState, depth int) error {
+	location, err := base.Parse(target)
+	if err != nil {
+		fmt.Fprintln(st.out, au.Red("Error reading meta refresh target:"), au.Red(err))
+		return fmt.Errorf("reading meta refresh target: %w", err)
 	}
+	if opts.NoRedirect {
+		fmt.Fprintln(st.out, au.Yellow("Meta refresh to"), au.Yellow(displayURL(location.String())), au.Yellow("not followed, redirects are disabled"))
+		return nil
+	}
+	if depth >= opts.MaxRedirects {
+		fmt.Fprintln(st.out, au.Yellow("Maximum redirects reached, not following meta refresh"))
+		return nil
+	}
+	for _, visited := range st.responses {
+		if visited.URL == location.String() {
+			fmt.Fprintln(st.out, au.Yellow("Meta refresh loops back to"), au.Yellow(displayURL(location.String())), au.Yellow("not following"))
+			return nil
+		}
+	}
+
+	st.responses[len(st.responses)-1].MetaRefresh = true
+	fmt.Fprintln(st.out, au.Magenta("Meta refresh to:"), au.Cyan(displayURL(location.String())))
+	logger.Debug("following meta refresh", "from", base.String(), "to", location.String())
+	return performGetRequestRecursive(client, location.String(), opts, st, depth+1)
+}
+
+// addHopTimings adds one hop's request sending and server processing time to
+// the combined request stats, so a redirect chain reports their sum
+func addHopTimings(st *requestState, start time.Time, requestSendingTime time.Duration) {
+	serverProcessingTime := time.Since(start) - requestSendingTime
+	if len(st.timeStats.CommonTimmings) > 0 {
+		if wait := st.timeStats.CommonTimmings[len(st.timeStats.CommonTimmings)-1].WaitingForServerTime; wait > 0 {
+			serverProcessingTime = wait
+		}
+	}
+
+	st.timeStats.RequestSendingTime += requestSendingTime
+	st.timeStats.ServerProcessingTime += serverProcessingTime
+}
+
+// headerSize is the size of the status line and headers serialized as
+// HTTP/1.1. HTTP/2 and HTTP/3 compress headers, so there it is an upper bound
+func headerSize(resp *http.Response) int64 {
+	// "HTTP/1.1 200 OK\r\n", Status already holds the code and reason
+	size := len(resp.Proto) + 1 + len(resp.Status) + 2
+	for key, values := range resp.Header {
+		for _, value := range values {
+			size += len(key) + len(": ") + len(value) + 2
+		}
+	}
+	// the blank line ending the header block
+	return int64(size + 2)
+}
+
+// statusText is the reason phrase the server sent, or the standard one when
+// the status line had none
+func statusText(resp *http.Response) string {
+	if text := strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))); text != "" {
+		return text
+	}
+	return http.StatusText(resp.StatusCode)
+}
+
+// printRequestHeaders lists the request headers sorted by name, HTTP/2 sends
+// them lowercase and with its :pseudo headers first
+func printRequestHeaders(w io.Writer, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, au.Green("Request headers:"))
+	for _, key := range keys {
+		for _, value := range header[key] {
+			fmt.Fprintln(w, au.Green(key+": "), au.Blue(value))
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+// pinnedHeaders lead the response header listing in this order, the rest
+// follow alphabetically so repeated runs print the same thing
+var pinnedHeaders = []string{"Content-Type", "Content-Length", "Server"}
+
+func sortedHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	isPinned := make(map[string]bool)
+	for _, key := range pinnedHeaders {
+		isPinned[key] = true
+		if _, ok := header[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	pinned := len(keys)
+	for key := range header {
+		if !isPinned[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys[pinned:])
+	return keys
+}
+
+func isCertificateError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	return errors.As(err, &verifyErr) || errors.As(err, &unknownAuthErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// isClientCertificateRequired matches the TLS alerts a server sends when it
+// wanted a client certificate and did not get an acceptable one. TLS 1.2 has
+// no alert of its own for a missing certificate, servers send a plain
+// handshake failure, so that one only counts when the handshake made for
+// ctx was asked for a certificate
+func isClientCertificateRequired(ctx context.Context, err error) bool {
+	alert, ok := remoteTLSAlert(err)
+	if !ok {
+		return false
+	}
+	switch alert {
+	case alertCertificateRequired, alertBadCertificate:
+		return true
+	case alertHandshakeFailure:
+		return certificateRequested(ctx)
+	}
+	return false
 }
 
 func formatDuration(d time.Duration) string {
@@ -220,81 +1512,325 @@ func formatDuration(d time.Duration) string {
 	return fmt.Sprintf("%s%s", formattedDurationVal, matches[2])
 }
 
-func createHTTPTrace() *httptrace.ClientTrace {
-	var traceStart, connect, dns, tlsHandshake time.Time
+// parseAltSvc extracts the protocol ids from an Alt-Svc header such as
+// h3=":443"; ma=86400, h2=":443"
+func parseAltSvc(header string) []string {
+	var protocols []string
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(header, ",") {
+		alternative, _, _ := strings.Cut(entry, ";")
+		protocol, _, found := strings.Cut(strings.TrimSpace(alternative), "=")
+		if !found || protocol == "" || seen[protocol] {
+			continue
+		}
+		seen[protocol] = true
+		protocols = append(protocols, protocol)
+	}
+	return protocols
+}
+
+func formatDNSDuration(t timmingsCommon) string {
+	if t.PhasesUnavailable {
+		return "n/a"
+	}
+	if t.DNSSkipped {
+		if t.DNSSkipReason != "" {
+			return "skipped (" + t.DNSSkipReason + ")"
+		}
+		return "skipped"
+	}
+	if t.ConnectionReused {
+		return "reused"
+	}
+	return formatDuration(t.DNSLookupTime)
+}
+
+// dnsSkipReason tells an IP literal target from one pinned with -resolve.
+// Anything else dialed without a lookup, such as through a proxy, gets no reason
+func dnsSkipReason(hostPort string, resolve map[string]string) string {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	if net.ParseIP(host) != nil {
+		return "IP literal"
+	}
+	if _, ok := unixSockets[host]; ok {
+		return "unix socket"
+	}
+	if _, ok := resolve[strings.ToLower(host)]; ok {
+		return "pinned by -resolve"
+	}
+	return ""
+}
+
+// colorizeDuration pads d like the other timing columns and colors it by thresholds
+func colorizeDuration(d time.Duration, thresholds timingThresholds) aurora.Value {
+	text := fmt.Sprintf("%-10s", formatDuration(d))
+	switch {
+	case d > thresholds.Bad:
+		return au.Red(text)
+	case d >= thresholds.Good:
+		return au.Yellow(text)
+	default:
+		return au.Green(text)
+	}
+}
+
+// phaseShare describes d as a share of the time from starting the request to
+// its first byte. TTFB is counted from a ready connection, so that span is
+// the connection phases plus TTFB. Phases a reused connection skipped get none
+func phaseShare(t timmingsCommon, d time.Duration) string {
+	firstByte := t.DNSLookupTime + t.TCPConnTime + t.TLSHandshakeTime + t.TTFB
+	if t.PhasesUnavailable || d <= 0 || firstByte <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%.0f%% of time to first byte)", float64(d)/float64(firstByte)*100)
+}
+
+// formatTCPDuration marks the connect phase of a unix socket as not applicable
+func formatTCPDuration(t timmingsCommon) string {
+	if t.UnixSocket {
+		return "n/a (unix socket)"
+	}
+	return formatPhaseDuration(t, t.TCPConnTime)
+}
+
+// formatPhaseDuration marks phases the transport could not trace
+func formatPhaseDuration(t timmingsCommon, d time.Duration) string {
+	if t.PhasesUnavailable {
+		return "n/a"
+	}
+	return formatDuration(d)
+}
+
+// createHTTPTrace records the phase timings of each connection, handing them
+// to done once the first response byte arrives, and the header fields
+// written on the wire into sent. Progress is narrated to w. resolve is only
+// consulted to explain a skipped lookup
+func createHTTPTrace(w io.Writer, sent http.Header, resolve map[string]string, done func(timmingsCommon)) *httptrace.ClientTrace {
+	var getConn, requestStart, connect, dns, tlsHandshake, wroteRequest time.Time
+	// HTTP/2 writes the request and reads the response on separate goroutines
+	var wroteMu sync.Mutex
 	var times timmingsCommon
+	var dnsStarted bool
+	var hostPort string
 
 	return &httptrace.ClientTrace{
-		DNSStart: func(_ httptrace.DNSStartInfo) {
+		GetConn: func(addr string) {
+			getConn = time.Now()
+			hostPort = addr
+			// a retry writes its headers again
+			for key := range sent {
+				delete(sent, key)
+			}
+		},
+		WroteHeaderField: func(key string, values []string) {
+			sent[key] = append(sent[key], values...)
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
 			dns = time.Now()
-			fmt.Println(aurora.Magenta("DNS lookup started."))
+			dnsStarted = true
+			times.DNSHost = info.Host
+			times.DNSQueueTime = dns.Sub(getConn)
+			fmt.Fprintln(w, au.Magenta("DNS lookup started."))
 		},
-		DNSDone: func(_ httptrace.DNSDoneInfo) {
+		DNSDone: func(info httptrace.DNSDoneInfo) {
 			times.DNSLookupTime = time.Since(dns)
+			logger.Debug("dns done", "host", times.DNSHost, "took", times.DNSLookupTime, "err", info.Err)
+			times.DNSCoalesced = info.Coalesced
+			if info.Err != nil {
+				fmt.Fprintln(w, au.Red("DNS lookup failed:"), au.Red(info.Err))
+				return
+			}
+			for _, addr := range info.Addrs {
+				times.ResolvedIPs = append(times.ResolvedIPs, addr.String())
+			}
 		},
-		ConnectStart: func(_, _ string) {
+		ConnectStart: func(network, _ string) {
 			connect = time.Now()
-			fmt.Println(aurora.Magenta("TCP connection started."))
+			times.UnixSocket = network == "unix"
+			if times.UnixSocket {
+				fmt.Fprintln(w, au.Magenta("Unix socket connection started."))
+				return
+			}
+			fmt.Fprintln(w, au.Magenta("TCP connection started."))
 		},
 		ConnectDone: func(_, _ string, err error) {
 			if err != nil {
-				fmt.Printf("Error during connection: %v\n", err)
+				fmt.Fprintf(w, "Error during connection: %v\n", err)
+				return
+			}
+			// there is no TCP handshake on a unix socket to report
+			if times.UnixSocket {
 				return
 			}
 			times.TCPConnTime = time.Since(connect)
+			logger.Debug("tcp connected", "took", times.TCPConnTime)
 		},
 		TLSHandshakeStart: func() {
 			tlsHandshake = time.Now()
-			fmt.Println(aurora.Magenta("TLS handshake started."))
+			fmt.Fprintln(w, au.Magenta("TLS handshake started."))
 		},
-		TLSHandshakeDone: func(_ tls.ConnectionState, _ error) {
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
 			times.TLSHandshakeTime = time.Since(tlsHandshake)
+			logger.Debug("tls handshake done", "took", times.TLSHandshakeTime, "err", err)
+			times.TLSVersion = getTLSVersion(state.Version)
+			times.TLSCipherSuite = getTLSCipherSuite(state.CipherSuite)
+			times.ALPNProtocol = state.NegotiatedProtocol
+			times.OCSPStapled = len(state.OCSPResponse) > 0
+			if times.OCSPStapled {
+				times.OCSPStatus, times.OCSPNextUpdate = parseStapledOCSP(state)
+			}
+			for _, cert := range state.PeerCertificates {
+				times.TLSCertSubjects = append(times.TLSCertSubjects, cert.Subject.String())
+				times.TLSCertIssuers = append(times.TLSCertIssuers, cert.Issuer.String())
+				times.TLSCertExpiry = append(times.TLSCertExpiry, cert.NotAfter)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			// TTFB counts from here, the connection is ready whether it was
+			// just dialed or reused, so the request is about to be sent
+			requestStart = time.Now()
+			times.RemoteAddr = info.Conn.RemoteAddr().String()
+			times.ConnectionReused = info.Reused
+			// a fresh connection without a lookup was dialed straight to an IP
+			times.DNSSkipped = !dnsStarted && !info.Reused
+			if times.DNSSkipped {
+				times.DNSSkipReason = dnsSkipReason(hostPort, resolve)
+			}
+			logger.Debug("got connection", "remote", times.RemoteAddr, "reused", info.Reused)
+		},
+		// a reused connection skips every hook above, this one always fires
+		WroteRequest: func(_ httptrace.WroteRequestInfo) {
+			wroteMu.Lock()
+			wroteRequest = time.Now()
+			wroteMu.Unlock()
 		},
 		GotFirstResponseByte: func() {
-			traceStart = time.Now()
-			fmt.Println(aurora.Magenta("Received first response byte."))
-			times.TTFB = time.Since(traceStart)
+			wroteMu.Lock()
+			wrote := wroteRequest
+			wroteMu.Unlock()
+			if !wrote.IsZero() {
+				times.WaitingForServerTime = time.Since(wrote)
+			}
+			times.TTFB = time.Since(requestStart)
+			logger.Debug("first response byte", "ttfb", times.TTFB)
+			fmt.Fprintln(w, au.Magenta("Received first response byte."))
 
-			//assuming last activity is reading the body so we append
-			timeStats.CommonTimmings = append(timeStats.CommonTimmings, times)
+			//assuming last activity is reading the body so we hand them over
+			done(times)
 		},
 	}
 }
 
-func printResponse(start time.Time, resp *http.Response, requestSendingTime time.Duration, headersArg bool) {
-	ttfb := time.Since(start)
-	serverProcessingTime := ttfb - requestSendingTime
-
-	timeStats.RequestSendingTime = requestSendingTime
-	timeStats.ServerProcessingTime = serverProcessingTime
-	timeStats.TotalRequestTime = time.Since(start)
+// printResponse reports the final response of a hop and returns its decoded body
+func printResponse(st *requestState, start time.Time, urlArg string, resp *http.Response, requestSendingTime time.Duration, opts requestOptions) ([]byte, error) {
+	addHopTimings(st, start, requestSendingTime)
+	// the total spans the whole chain, from the first hop's request
+	chainStart := start
+	if len(st.responses) > 0 {
+		chainStart = st.responses[0].Started
+	}
+	st.timeStats.TotalRequestTime = time.Since(chainStart)
 
-	fmt.Println()
-	fmt.Println(aurora.Green("Response status:"), aurora.Blue(resp.Status))
+	fmt.Fprintln(st.out)
+	fmt.Fprintln(st.out, au.Green("Response status:"), colorizeByStatus(resp.StatusCode, resp.Status))
 	if lastMod, ok := resp.Header["Last-Modified"]; ok {
-		fmt.Println(aurora.Green("Last Modified:"), aurora.Blue(lastMod))
+		fmt.Fprintln(st.out, au.Green("Last Modified:"), au.Blue(lastMod))
 	} else {
-		fmt.Println(aurora.Green("Last Modified header not present"))
+		fmt.Fprintln(st.out, au.Green("Last Modified header not present"))
+	}
+	if location := resp.Header.Get("Location"); location != "" {
+		fmt.Fprintln(st.out, au.Green("Location:"), au.Blue(location))
 	}
-	fmt.Println()
+	fmt.Fprintln(st.out)
 
-	if headersArg {
-		fmt.Println(aurora.Green("Response headers:"))
-		for key, values := range resp.Header {
-			for _, value := range values {
-				fmt.Println(aurora.Green(key+": "), aurora.Blue(value))
+	if opts.PrintHeaders {
+		fmt.Fprintln(st.out, au.Green("Response headers:"))
+		for _, key := range sortedHeaderKeys(resp.Header) {
+			if !headerMatches(opts.HeaderFilter, key) {
+				continue
 			}
+			// repeated headers keep the order the server sent them in
+			for _, value := range resp.Header[key] {
+				fmt.Fprintln(st.out, au.Green(key+": "), au.Blue(value))
+			}
+		}
+	}
+
+	var save io.Writer
+	closeSave := func() error { return nil }
+	if opts.SaveBody != "" {
+		var err error
+		if save, closeSave, err = createBodyFile(opts.SaveBody); err != nil {
+			fmt.Fprintln(st.out, au.Red("Error saving response body:"), au.Red(err))
+			return nil, fmt.Errorf("saving response body: %w", err)
 		}
 	}
 
-	// Calculate content download time
-	contentDownloadStart := time.Now()
-	_, err := io.ReadAll(resp.Body)
-	contentTransferTime := time.Since(contentDownloadStart)
+	// the body streams through the decoder into -save-body and -hash, it is
+	// only kept in memory when a meta refresh has to be looked for.
+	// HEAD responses have an empty body so the transfer time stays near zero
+	encoding := resp.Header.Get("Content-Encoding")
+	body, err := streamBody(resp.Body, encoding, opts, save, opts.FollowMetaRefresh)
+	if closeErr := closeSave(); body.SaveErr == nil {
+		body.SaveErr = closeErr
+	}
 	if err != nil {
-		fmt.Println(aurora.Red("Error reading response body:"), aurora.Red(err))
-		return
+		fmt.Fprintln(st.out, au.Red("Error reading response body:"), au.Red(err))
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	if body.Truncated {
+		fmt.Fprintln(st.out, au.Green("Transferred:"), au.Yellow(fmt.Sprintf("≥ %d (truncated)", body.WireSize)))
+	} else {
+		if body.DecodeErr != nil {
+			fmt.Fprintln(st.out, au.Red("Error decoding response body:"), au.Red(body.DecodeErr))
+		}
+		if body.WireSize > 0 {
+			printTransferSize(st.out, encoding, body.WireSize, body.Size)
+		}
+	}
+
+	headerBytes := headerSize(resp)
+	fmt.Fprintln(st.out, au.Green("Headers:"), au.Blue(fmt.Sprintf("%d B,", headerBytes)),
+		au.Green("Body:"), au.Blue(fmt.Sprintf("%d B,", body.WireSize)),
+		au.Green("Total:"), au.Blue(fmt.Sprintf("%d B", headerBytes+body.WireSize)))
+
+	if body.Hash != "" {
+		fmt.Fprintln(st.out, au.Green("Body "+opts.Hash+":"), au.Blue(body.Hash))
+	}
+
+	if opts.SaveBody != "" {
+		if body.SaveErr != nil {
+			fmt.Fprintln(st.out, au.Red("Error saving response body:"), au.Red(body.SaveErr))
+			return nil, fmt.Errorf("saving response body: %w", body.SaveErr)
+		}
+		if opts.SaveBody != "-" {
+			fmt.Fprintln(st.out, au.Green("Body saved to:"), au.Blue(opts.SaveBody))
+		}
+		// stderr, so the warning also shows when the body went to stdout
+		switch {
+		case body.Truncated:
+			fmt.Fprintln(os.Stderr, au.Yellow("Saved body is incomplete, -max-body stopped reading after"), au.Yellow(fmt.Sprintf("%d bytes", body.WireSize)))
+		case body.DecodeErr != nil:
+			fmt.Fprintln(os.Stderr, au.Yellow("Saved body was not fully decoded:"), au.Yellow(body.DecodeErr))
+		}
 	}
 
-	timeStats.ContentTransferTime = contentTransferTime
+	st.timeStats.ContentTransferTime = body.TransferTime
+	st.responses = append(st.responses, responseInfo{
+		URL:         urlArg,
+		Response:    resp,
+		StatusCode:  resp.StatusCode,
+		StatusText:  statusText(resp),
+		ContentSize: body.Size,
+		WireSize:    body.WireSize,
+		HeaderSize:  headerBytes,
+		Started:     start,
+		BodyHash:    body.Hash,
+		Truncated:   body.Truncated,
+	})
+	return body.Decoded, nil
 }
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyFunc(t *testing.T) {
+	unixSockets["docker.sock"] = "/var/run/docker.sock"
+	defer delete(unixSockets, "docker.sock")
+
+	flagProxy, _ := url.Parse("http://flag-proxy:8080")
+	tests := []struct {
+		name   string
+		proxy  *url.URL
+		env    map[string]string
+		target string
+		want   string
+	}{
+		{"flag", flagProxy, nil, "https://example.com/", "http://flag-proxy:8080"},
+		{"flag over env", flagProxy, map[string]string{"HTTPS_PROXY": "http://env-proxy:3128"}, "https://example.com/", "http://flag-proxy:8080"},
+		{"https env", nil, map[string]string{"HTTPS_PROXY": "http://env-proxy:3128"}, "https://example.com/", "http://env-proxy:3128"},
+		{"http env", nil, map[string]string{"HTTP_PROXY": "http://env-proxy:3128"}, "http://example.com/", "http://env-proxy:3128"},
+		{"http env leaves https", nil, map[string]string{"HTTP_PROXY": "http://env-proxy:3128"}, "https://example.com/", ""},
+		{"no proxy match", nil, map[string]string{"HTTPS_PROXY": "http://env-proxy:3128", "NO_PROXY": "example.com"}, "https://www.example.com/", ""},
+		{"no proxy miss", nil, map[string]string{"HTTPS_PROXY": "http://env-proxy:3128", "NO_PROXY": "example.com"}, "https://example.org/", "http://env-proxy:3128"},
+		{"none", nil, nil, "https://example.com/", ""},
+		{"unix socket with flag", flagProxy, nil, "http://docker.sock/info", ""},
+		{"unix socket with env", nil, map[string]string{"HTTP_PROXY": "http://env-proxy:3128"}, "http://docker.sock/info", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "REQUEST_METHOD"} {
+				t.Setenv(key, tt.env[key])
+			}
+			// the proxy is wired through the transport the client is built with
+			client := createHTTPClient(clientOptions{Timeout: time.Second, Network: "tcp", Proxy: tt.proxy})
+			req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+			got, err := client.Transport.(*http.Transport).Proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == nil && tt.want != "") || (got != nil && got.String() != tt.want) {
+				t.Errorf("proxy for %s is %v, want %q", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSocksProxyReplacesDialer(t *testing.T) {
+	socks, _ := url.Parse("socks5://127.0.0.1:1080")
+	transport := createHTTPClient(clientOptions{Timeout: time.Second, Network: "tcp", Proxy: socks}).Transport.(*http.Transport)
+	if transport.Proxy != nil {
+		t.Error("a socks5 proxy is also set as the HTTP proxy")
+	}
+}
+
+func TestReusedConnectionTimings(t *testing.T) {
+	const wait = 20 * time.Millisecond
+	stdout := out
+	out = io.Discard
+	defer func() { out = stdout }()
+
+	// HTTP/2 traces the write and the first byte from different goroutines
+	for _, h2 := range []bool{false, true} {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(wait)
+		}))
+		srv.EnableHTTP2 = h2
+		srv.StartTLS()
+		defer srv.Close()
+
+		client := createHTTPClient(clientOptions{Timeout: 5 * time.Second, Insecure: true, Network: "tcp"})
+		opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10}
+		var last timmingsCommon
+		for i := 0; i < 2; i++ {
+			timeStats, responses = timmings{}, nil
+			if err := performGetRequest(client, srv.URL, opts); err != nil {
+				t.Fatal(err)
+			}
+			if len(timeStats.CommonTimmings) != 1 {
+				t.Fatalf("got %d traced connections, want 1", len(timeStats.CommonTimmings))
+			}
+			last = timeStats.CommonTimmings[0]
+		}
+
+		// the second request rides the kept alive connection, the server's
+		// wait has to show up all the same
+		if !last.ConnectionReused {
+			t.Fatalf("second request over %s did not reuse the connection", responses[0].Response.Proto)
+		}
+		if last.WaitingForServerTime < wait {
+			t.Errorf("WaitingForServerTime %v on a reused connection, want at least %v", last.WaitingForServerTime, wait)
+		}
+		if last.TTFB < last.WaitingForServerTime {
+			t.Errorf("TTFB %v is shorter than WaitingForServerTime %v", last.TTFB, last.WaitingForServerTime)
+		}
+		if h2 && responses[0].Response.ProtoMajor != 2 {
+			t.Errorf("response came over %s, want HTTP/2", responses[0].Response.Proto)
+		}
+	}
+}
+
+func TestRunTargetsConcurrentURLs(t *testing.T) {
+	var inFlight, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		} else {
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	var report bytes.Buffer
+	stdout, stdreport := out, reportOut
+	out, reportOut = io.Discard, &report
+	defer func() { out, reportOut = stdout, stdreport }()
+
+	targets := []string{srv.URL + "/slow", srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"}
+	opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10}
+	run := runOptions{Format: formatJSONL, Repeat: 1, ConcurrentURLs: 2}
+	if code := runTargets(srv.Client(), targets, opts, run); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+
+	if got := atomic.LoadInt32(&peak); got != 2 {
+		t.Errorf("%d targets in flight at most, want 2", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != len(targets) {
+		t.Fatalf("got %d jsonl lines, want %d:\n%s", len(lines), len(targets), report.String())
+	}
+	var urls []string
+	for _, line := range lines {
+		var got struct {
+			URL       string `json:"url"`
+			Responses []struct {
+				StatusCode int `json:"status_code"`
+			} `json:"responses"`
+		}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("bad jsonl line %q: %v", line, err)
+		}
+		if len(got.Responses) != 1 || got.Responses[0].StatusCode != http.StatusOK {
+			t.Errorf("line for %s does not carry its own response: %s", got.URL, line)
+		}
+		urls = append(urls, got.URL)
+	}
+	// each line is written as its target completes, the slow one comes last
+	if urls[len(urls)-1] != srv.URL+"/slow" {
+		t.Errorf("lines are not in completion order: %v", urls)
+	}
+}
+
+func TestRunTargetsOneReportDocument(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	stdout, stdreport := out, reportOut
+	defer func() { out, reportOut = stdout, stdreport }()
+
+	targets := []string{srv.URL + "/old", srv.URL + "/a", "http://127.0.0.1:1"}
+	opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10}
+	for _, concurrent := range []int{1, 2} {
+		var report bytes.Buffer
+		out, reportOut = io.Discard, &report
+		run := runOptions{Format: formatJSON, Repeat: 1, ConcurrentURLs: concurrent}
+		runTargets(srv.Client(), targets, opts, run)
+
+		var got []struct {
+			URL       string            `json:"url"`
+			Error     string            `json:"error"`
+			Responses []json.RawMessage `json:"responses"`
+		}
+		if err := json.Unmarshal(report.Bytes(), &got); err != nil {
+			t.Fatalf("-concurrent-urls %d: json report is not one document: %v\n%s", concurrent, err, report.String())
+		}
+		if len(got) != len(targets) {
+			t.Fatalf("-concurrent-urls %d: %d targets in the json array, want %d", concurrent, len(got), len(targets))
+		}
+		for _, target := range got {
+			if target.URL == "http://127.0.0.1:1" && target.Error == "" {
+				t.Errorf("-concurrent-urls %d: the failed target has no error", concurrent)
+			}
+			if target.URL == srv.URL+"/old" && len(target.Responses) != 2 {
+				t.Errorf("-concurrent-urls %d: %d responses for the redirect, want 2", concurrent, len(target.Responses))
+			}
+		}
+
+		report.Reset()
+		run.Format = formatHAR
+		runTargets(srv.Client(), targets, opts, run)
+
+		var har harDocument
+		if err := json.Unmarshal(report.Bytes(), &har); err != nil {
+			t.Fatalf("-concurrent-urls %d: har report is not one document: %v\n%s", concurrent, err, report.String())
+		}
+		// the redirect and its target, then /a, the failed target has no entry
+		if len(har.Log.Entries) != 3 {
+			t.Errorf("-concurrent-urls %d: %d HAR entries, want 3", concurrent, len(har.Log.Entries))
+		}
+	}
+}
+
+// lineTimes records when each write to it happened
+type lineTimes struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (l *lineTimes) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.times = append(l.times, time.Now())
+	return len(p), nil
+}
+
+func TestRunTargetsStreamsJSONLines(t *testing.T) {
+	slowDone := make(chan time.Time, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(300 * time.Millisecond)
+			slowDone <- time.Now()
+		}
+	}))
+	defer srv.Close()
+
+	var lines lineTimes
+	stdout, stdreport := out, reportOut
+	out, reportOut = io.Discard, &lines
+	defer func() { out, reportOut = stdout, stdreport }()
+
+	targets := []string{srv.URL + "/slow", srv.URL + "/fast"}
+	opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10}
+	run := runOptions{Format: formatJSONL, Repeat: 1, ConcurrentURLs: 2}
+	if code := runTargets(srv.Client(), targets, opts, run); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+
+	if len(lines.times) != len(targets) {
+		t.Fatalf("got %d writes, want %d", len(lines.times), len(targets))
+	}
+	// the fast target's line must not wait for the slow one
+	if done := <-slowDone; !lines.times[0].Before(done) {
+		t.Error("first line was written only after the slow target finished")
+	}
+}
+
+func TestRunRequestClientCertificate(t *testing.T) {
+	ca := newTestCA(t, "headview client CA")
+	clientCert := newTestLeaf(t, &ca, nil)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Cert)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10}
+	// TLS 1.2 fails in the handshake, 1.3 only on the first read after it
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		t.Run(tls.VersionName(version), func(t *testing.T) {
+			client := createHTTPClient(clientOptions{Timeout: 5 * time.Second, Insecure: true, Network: "tcp", MaxTLSVersion: version})
+			var buf bytes.Buffer
+			st := &requestState{out: &buf}
+			err := runRequest(client, srv.URL, opts, st)
+			if _, ok := remoteTLSAlert(err); !ok || !strings.HasPrefix(err.Error(), "client certificate required") {
+				t.Fatalf("got %v, want a client certificate error", err)
+			}
+			if !strings.Contains(buf.String(), "requires a client certificate") {
+				t.Errorf("output does not explain the failure:\n%s", buf.String())
+			}
+
+			client = createHTTPClient(clientOptions{Timeout: 5 * time.Second, Insecure: true, Network: "tcp", MaxTLSVersion: version,
+				Certificates: []tls.Certificate{clientCert.tlsCertificate()}})
+			st = &requestState{out: io.Discard}
+			if err := runRequest(client, srv.URL, opts, st); err != nil {
+				t.Fatalf("request with -cert failed: %v", err)
+			}
+			if got := st.responses[len(st.responses)-1].StatusCode; got != http.StatusOK {
+				t.Errorf("status %d with -cert, want 200", got)
+			}
+		})
+	}
+}
+
+func TestIsClientCertificateRequired(t *testing.T) {
+	asked := withCertificateRequestFlag(context.Background())
+	asked.Value(certificateRequestKey{}).(*atomic.Bool).Store(true)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"certificate required", context.Background(), &net.OpError{Op: "remote error", Err: alertCertificateRequired}, true},
+		{"bad certificate", context.Background(), &net.OpError{Op: "remote error", Err: alertBadCertificate}, true},
+		{"handshake failure after a certificate request", asked, &net.OpError{Op: "remote error", Err: alertHandshakeFailure}, true},
+		{"handshake failure without one", withCertificateRequestFlag(context.Background()), &net.OpError{Op: "remote error", Err: alertHandshakeFailure}, false},
+		{"local alert", context.Background(), &net.OpError{Op: "local error", Err: alertBadCertificate}, false},
+		{"other error", context.Background(), io.ErrUnexpectedEOF, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("Get %q: %w", "https://example.com", tt.err)
+			if got := isClientCertificateRequired(tt.ctx, err); got != tt.want {
+				t.Errorf("isClientCertificateRequired(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
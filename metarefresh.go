@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// metaRefreshTarget returns the URL a <meta http-equiv="refresh"> tag sends
+// the page to, empty when there is none or it only reloads the page
+func metaRefreshTarget(body []byte) string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var target string
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if !strings.EqualFold(strings.TrimSpace(s.AttrOr("http-equiv", "")), "refresh") {
+			return true
+		}
+		target = parseMetaRefresh(s.AttrOr("content", ""))
+		return target == ""
+	})
+	return target
+}
+
+// parseMetaRefresh reads the URL out of a refresh content value such as
+// "0; url=/next" or "5;URL='https://example.com/'"
+func parseMetaRefresh(content string) string {
+	_, rest, found := strings.Cut(content, ";")
+	if !found {
+		// "0, url=..." is accepted by browsers too
+		if _, rest, found = strings.Cut(content, ","); !found {
+			return ""
+		}
+	}
+
+	rest = strings.TrimSpace(rest)
+	if len(rest) >= 4 && strings.EqualFold(rest[:4], "url=") {
+		rest = rest[4:]
+	}
+	return strings.Trim(strings.TrimSpace(rest), `'"`)
+}
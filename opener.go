@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// openCommand returns the command that opens path with the desktop's default
+// application for its type
+func openCommand(path string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", path)
+	case "windows":
+		// start treats its first quoted argument as the window title
+		return exec.Command("cmd", "/c", "start", "", path)
+	}
+	return exec.Command("xdg-open", path)
+}
+
+// openHAR writes har to a temporary file and hands it to the default viewer.
+// The path is always printed, so a missing opener still leaves the file usable
+func openHAR(har harDocument) error {
+	f, err := os.CreateTemp("", "headview-*.har")
+	if err != nil {
+		return fmt.Errorf("creating HAR file: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(har); err != nil {
+		return fmt.Errorf("writing HAR file: %w", err)
+	}
+	fmt.Fprintln(out, au.Green("HAR written to:"), au.Blue(f.Name()))
+
+	// the viewer outlives us, so it is started and not waited for
+	if err := openCommand(f.Name()).Start(); err != nil {
+		fmt.Fprintln(out, au.Yellow("Could not open it automatically:"), au.Yellow(err))
+	}
+	return nil
+}
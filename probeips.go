@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ipProbe is one address of a -probe-all-ips run
+type ipProbe struct {
+	IP     net.IP
+	Status int
+	Timing timmingsCommon
+	Total  time.Duration
+	Err    error
+}
+
+// probeAllIPs requests every target once through each address its host
+// resolves to. Only the dial is pinned, the URL keeps SNI and Host as they
+// were, so a slow or misconfigured backend in a pool stands out. Returns 1
+// when a host could not be resolved or any address failed
+func probeAllIPs(targets []string, clientOpts clientOptions, opts requestOptions) int {
+	exitCode := 0
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Hostname() == "" {
+			fmt.Fprintln(out, au.Red("Cannot find a host in:"), au.Red(target))
+			exitCode = 1
+			continue
+		}
+
+		host := strings.ToLower(u.Hostname())
+		result, err := resolveOnly(opts.context(), host, clientOpts.Network, clientOpts.Resolve, clientOpts.Timeout)
+		if err != nil {
+			fmt.Fprintln(out, au.Red("DNS resolution failed:"), au.Red(err))
+			exitCode = 1
+			continue
+		}
+		ips := append(result.IPv4, result.IPv6...)
+		fmt.Fprintln(out, au.Magenta("Probing"), au.Blue(len(ips)), au.Magenta("addresses of:"), au.Cyan(displayURL(target)))
+
+		var probes []ipProbe
+		for _, ip := range ips {
+			probe := probeIP(target, host, ip, clientOpts, opts)
+			if probe.Err != nil {
+				exitCode = 1
+			}
+			probes = append(probes, probe)
+		}
+		printIPProbeTable(probes)
+	}
+	return exitCode
+}
+
+// probeIP requests target on a client of its own, so every address gets a
+// fresh connection with its own DNS, TCP and TLS phases
+func probeIP(target, host string, ip net.IP, clientOpts clientOptions, opts requestOptions) ipProbe {
+	pinned := make(map[string]string, len(clientOpts.Resolve)+1)
+	for name, addr := range clientOpts.Resolve {
+		pinned[name] = addr
+	}
+	pinned[host] = ip.String()
+	clientOpts.Resolve = pinned
+
+	client := createHTTPClient(clientOpts)
+	defer client.CloseIdleConnections()
+
+	probe := ipProbe{IP: ip}
+	info, err := requestFinal(client, target, opts, opts.Method)
+	if err != nil {
+		probe.Err = err
+		return probe
+	}
+	probe.Status = info.StatusCode
+	probe.Total = timeStats.TotalRequestTime
+	if len(timeStats.CommonTimmings) > 0 {
+		probe.Timing = timeStats.CommonTimmings[0]
+	}
+	return probe
+}
+
+// printIPProbeTable lines the addresses up with the fastest TTFB in green
+// and the slowest in red
+func printIPProbeTable(probes []ipProbe) {
+	var fastest, slowest time.Duration
+	for _, p := range probes {
+		if p.Err != nil {
+			continue
+		}
+		if fastest == 0 || p.Timing.TTFB < fastest {
+			fastest = p.Timing.TTFB
+		}
+		if p.Timing.TTFB > slowest {
+			slowest = p.Timing.TTFB
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Address\tStatus\tTCP\tTLS\tTTFB\tTotal")
+	for _, p := range probes {
+		if p.Err != nil {
+			fmt.Fprintf(w, "%s\t%s\n", p.IP, au.Red(p.Err))
+			continue
+		}
+		ttfb := au.Blue(formatDuration(p.Timing.TTFB))
+		switch {
+		case len(probes) > 1 && p.Timing.TTFB == fastest:
+			ttfb = au.Green(formatDuration(p.Timing.TTFB))
+		case len(probes) > 1 && p.Timing.TTFB == slowest:
+			ttfb = au.Red(formatDuration(p.Timing.TTFB))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.IP, colorizeByStatus(p.Status, fmt.Sprint(p.Status)),
+			au.Blue(formatDuration(p.Timing.TCPConnTime)), au.Blue(formatDuration(p.Timing.TLSHandshakeTime)),
+			ttfb, au.Blue(formatDuration(p.Total)))
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+}
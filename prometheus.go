@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// prometheusTarget is what one target contributes to the -prometheus output
+type prometheusTarget struct {
+	URL     string
+	Timings timmings
+	Status  int
+}
+
+// labelEscaper escapes label values as the text exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheus prints the final connection timings and status of each
+// target in the Prometheus text exposition format, one sample per target
+// under each metric family so the output works as a textfile collector
+func writePrometheus(w io.Writer, targets []prometheusTarget) error {
+	metrics := []struct {
+		name  string
+		help  string
+		value func(prometheusTarget) float64
+	}{
+		{"headview_dns_seconds", "DNS lookup time", func(p prometheusTarget) float64 { return lastConnection(p.Timings).DNSLookupTime.Seconds() }},
+		{"headview_tcp_seconds", "TCP connection time", func(p prometheusTarget) float64 { return lastConnection(p.Timings).TCPConnTime.Seconds() }},
+		{"headview_tls_seconds", "TLS handshake time", func(p prometheusTarget) float64 { return lastConnection(p.Timings).TLSHandshakeTime.Seconds() }},
+		{"headview_ttfb_seconds", "Time to first byte", func(p prometheusTarget) float64 { return lastConnection(p.Timings).TTFB.Seconds() }},
+		{"headview_total_seconds", "Total request time", func(p prometheusTarget) float64 { return p.Timings.TotalRequestTime.Seconds() }},
+		{"headview_status_code", "HTTP status code of the final response, 0 when the request failed", func(p prometheusTarget) float64 { return float64(p.Status) }},
+	}
+
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", metric.name, metric.help, metric.name); err != nil {
+			return err
+		}
+		for _, target := range targets {
+			if _, err := fmt.Fprintf(w, "%s{url=\"%s\"} %g\n", metric.name, labelEscaper.Replace(target.URL), metric.value(target)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lastConnection returns the connection that carried the final response
+func lastConnection(t timmings) timmingsCommon {
+	if len(t.CommonTimmings) == 0 {
+		return timmingsCommon{}
+	}
+	return t.CommonTimmings[len(t.CommonTimmings)-1]
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// printRedirectChain numbers every hop with its status and where it led,
+// flagging downgrades to plain http and hops to another domain
+func printRedirectChain(infos []responseInfo) {
+	fmt.Fprintln(out, au.Green("Redirect chain:"))
+
+	for i, info := range infos {
+		prefix := fmt.Sprintf("%3d.", i+1)
+		if i == len(infos)-1 {
+			fmt.Fprintln(out, prefix, au.Blue(info.Response.StatusCode), au.Cyan(displayURL(info.URL)))
+			continue
+		}
+
+		from, err := url.Parse(info.URL)
+		if err != nil {
+			fmt.Fprintln(out, prefix, au.Blue(info.Response.StatusCode), au.Cyan(displayURL(info.URL)))
+			continue
+		}
+		to := infos[i+1].URL
+		if location, err := info.Response.Location(); err == nil {
+			to = location.String()
+		}
+
+		status := au.Blue(info.Response.StatusCode)
+		if info.MetaRefresh {
+			status = au.Blue(fmt.Sprintf("%d meta refresh", info.Response.StatusCode))
+		}
+		fmt.Fprintln(out, prefix, status, au.Cyan(displayURL(info.URL)), "->", au.Cyan(displayURL(to)))
+		for _, warning := range redirectWarnings(from, to) {
+			fmt.Fprintln(out, "    ", au.Yellow(warning))
+		}
+	}
+	fmt.Fprintln(out)
+}
+
+// redirectWarnings describes what is suspicious about a single hop
+func redirectWarnings(from *url.URL, to string) []string {
+	target, err := url.Parse(to)
+	if err != nil {
+		return nil
+	}
+
+	var warnings []string
+	if from.Scheme == "https" && target.Scheme == "http" {
+		warnings = append(warnings, "downgrade from https to http")
+	}
+	if !strings.EqualFold(from.Hostname(), target.Hostname()) {
+		warnings = append(warnings, "redirects to another domain: "+target.Hostname())
+	}
+	return warnings
+}
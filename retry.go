@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// retryBaseDelay is the wait before the first retry, doubled for each one after
+const retryBaseDelay = 100 * time.Millisecond
+
+// doWithRetry sends req, retrying up to opts.Retries times on connection errors
+// and, with opts.RetryStatus, on 502/503/504 responses. Returns the response
+// with the start time and number of the attempt that produced it. The caller
+// waits on opts.Limiter for the first attempt before the request deadline is
+// set, retries wait their turn here, and both that wait and the backoff
+// between attempts are cut short by the request context's deadline
+func doWithRetry(client *http.Client, req *http.Request, opts requestOptions, st *requestState) (*http.Response, time.Time, int, error) {
+	// abandoned attempts should not show up as extra connections
+	traced := len(st.timeStats.CommonTimmings)
+	delay := retryBaseDelay
+
+	for attempt := 1; ; attempt++ {
+		st.timeStats.CommonTimmings = st.timeStats.CommonTimmings[:traced]
+
+		if opts.Limiter != nil && attempt > 1 {
+			if err := opts.Limiter.Wait(req.Context()); err != nil {
+				return nil, time.Now(), attempt, fmt.Errorf("waiting for rate limit: %w", err)
+			}
+		}
+
+		// the cookie jar adds its cookies to the request it is given and the
+		// body is consumed by sending, so each attempt gets a fresh copy
+		attemptReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, time.Now(), attempt, fmt.Errorf("rewinding request body: %w", err)
+			}
+			attemptReq.Body = body
+		}
+
+		started := time.Now()
+		resp, err := client.Do(attemptReq)
+		if attempt > opts.Retries || !shouldRetry(req.Context(), resp, err, opts.RetryStatus) {
+			return resp, started, attempt, err
+		}
+
+		if err != nil {
+			fmt.Fprintln(st.out, au.Yellow("Attempt"), au.Yellow(attempt), au.Yellow("failed:"), au.Yellow(err))
+		} else {
+			resp.Body.Close()
+			fmt.Fprintln(st.out, au.Yellow("Attempt"), au.Yellow(attempt), au.Yellow("returned"), au.Yellow(resp.Status))
+		}
+		fmt.Fprintln(st.out, au.Yellow("Retrying in"), au.Yellow(delay))
+		logger.Debug("retrying", "url", req.URL.String(), "attempt", attempt, "delay", delay)
+
+		select {
+		case <-req.Context().Done():
+			return nil, started, attempt, req.Context().Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// shouldRetry reports whether an attempt failed in a way another attempt may fix.
+// TLS verification failures and an expired deadline will not go away on their own
+func shouldRetry(ctx context.Context, resp *http.Response, err error, retryStatus bool) bool {
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return false
+		}
+		return !isCertificateError(err) && !isClientCertificateRequired(ctx, err) && !isTLSVersionMismatch(err) && !isTLSRecordHeaderError(err)
+	}
+
+	if !retryStatus {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/temoto/robotstxt"
+)
+
+// fetchRobots loads robots.txt for the page's host. A missing or unreadable
+// file allows everything, as crawlers treat it
+func fetchRobots(ctx context.Context, client *http.Client, pageURL *url.URL, userAgent string) *robotstxt.RobotsData {
+	robotsURL := &url.URL{Scheme: pageURL.Scheme, Host: pageURL.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL.String(), nil)
+	if err != nil {
+		return nil
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(out, au.Yellow("Could not fetch robots.txt:"), au.Yellow(err))
+		return nil
+	}
+	defer resp.Body.Close()
+
+	robots, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		fmt.Fprintln(out, au.Yellow("Could not parse robots.txt:"), au.Yellow(err))
+		return nil
+	}
+	return robots
+}
+
+// robotsAllowed checks a resource against the page host's robots.txt, other
+// hosts have their own rules which are not fetched
+func robotsAllowed(opts sizeOptions, resourceURL *url.URL) bool {
+	if opts.robots == nil || resourceURL.Host != opts.robotsHost {
+		return true
+	}
+	path := resourceURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return opts.robots.TestAgent(path, opts.UserAgent)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/logrusorgru/aurora"
+)
+
+var securityHeaders = []string{
+	"Strict-Transport-Security",
+	"Content-Security-Policy",
+	"X-Frame-Options",
+	"X-Content-Type-Options",
+	"Referrer-Policy",
+	"Permissions-Policy",
+}
+
+func printSecurityHeaders(resp *http.Response) {
+	fmt.Fprintln(out, au.Green("Security headers:"))
+
+	var present int
+	for _, header := range securityHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			present++
+			fmt.Fprintf(out, "%28s %s\n", au.Green(header), au.Blue(value))
+		} else {
+			fmt.Fprintf(out, "%28s %s\n", au.Red(header), au.Red("missing"))
+		}
+	}
+
+	grade := securityGrade(present, len(securityHeaders))
+	fmt.Fprintln(out, au.Green("Security grade:"), colorizeGrade(grade), au.Blue(fmt.Sprintf("(%d/%d headers present)", present, len(securityHeaders))))
+	fmt.Fprintln(out)
+
+	printHSTS(resp)
+}
+
+// securityGrade drops one letter per missing header, bottoming out at F
+func securityGrade(present, total int) string {
+	grades := []string{"A", "B", "C", "D"}
+	missing := total - present
+	if missing < len(grades) {
+		return grades[missing]
+	}
+	return "F"
+}
+
+func colorizeGrade(grade string) aurora.Value {
+	switch grade {
+	case "A", "B":
+		return au.Green(grade)
+	case "C", "D":
+		return au.Yellow(grade)
+	default:
+		return au.Red(grade)
+	}
+}
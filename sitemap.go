@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// maxSitemapDepth bounds how far sitemap index files are followed
+const maxSitemapDepth = 3
+
+// sitemapDocument covers both a <urlset> and a <sitemapindex>
+type sitemapDocument struct {
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapResult is the outcome of requesting one page listed in a sitemap
+type sitemapResult struct {
+	URL    string
+	Status int
+	TTFB   time.Duration
+	Err    error
+}
+
+// discoverSitemapURLs returns the page URLs of a sitemap, following index
+// files into nested sitemaps. Gzipped sitemaps are detected by content
+func discoverSitemapURLs(ctx context.Context, client *http.Client, sitemapURL string, userAgent string, depth int, seen map[string]bool) ([]string, error) {
+	if seen[sitemapURL] {
+		return nil, nil
+	}
+	seen[sitemapURL] = true
+
+	req, err := http.NewRequestWithContext(ctx, "GET", sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	setAcceptEncoding(req)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching sitemap: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching sitemap %s: %s", sitemapURL, resp.Status)
+	}
+
+	wire, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading sitemap: %w", err)
+	}
+	body, err := decodeBody(resp.Header.Get("Content-Encoding"), wire)
+	if err != nil {
+		return nil, fmt.Errorf("decoding sitemap: %w", err)
+	}
+	// .xml.gz files are served gzipped without a Content-Encoding
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		if body, err = decodeBody("gzip", body); err != nil {
+			return nil, fmt.Errorf("decompressing sitemap: %w", err)
+		}
+	}
+
+	var doc sitemapDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("parsing sitemap %s: %w", sitemapURL, err)
+	}
+
+	var urls []string
+	for _, u := range doc.URLs {
+		if loc := sitemapTarget(u.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+	for _, nested := range doc.Sitemaps {
+		loc := sitemapTarget(nested.Loc)
+		if loc == "" {
+			continue
+		}
+		if depth >= maxSitemapDepth {
+			fmt.Fprintln(out, au.Yellow("Sitemap nesting too deep, skipping:"), au.Yellow(loc))
+			continue
+		}
+		nestedURLs, err := discoverSitemapURLs(ctx, client, loc, userAgent, depth+1, seen)
+		if err != nil {
+			fmt.Fprintln(out, au.Red("Error reading nested sitemap:"), au.Red(err))
+			continue
+		}
+		urls = append(urls, nestedURLs...)
+	}
+	return urls, nil
+}
+
+// sitemapTarget normalises a <loc> the way command line targets are. encoding/xml
+// keeps the whitespace of pretty-printed sitemaps, which no request accepts.
+// A sitemap is someone else's content, it does not get to reach a unix socket
+func sitemapTarget(loc string) string {
+	loc = strings.TrimSpace(loc)
+	if loc == "" || strings.HasPrefix(loc, "unix://") || strings.HasPrefix(loc, "http+unix://") {
+		return ""
+	}
+	return addDefaultProtocol(loc)
+}
+
+// crawlSitemaps requests every page listed in the sitemaps, workers at a
+// time, and prints a summary table in sitemap order
+func crawlSitemaps(client *http.Client, sitemaps []string, opts requestOptions, workers int) int {
+	exitCode := 0
+	for _, sitemapURL := range sitemaps {
+		fmt.Fprintln(out, au.Magenta("Reading sitemap:"), au.Cyan(displayURL(sitemapURL)))
+		pages, err := discoverSitemapURLs(opts.context(), client, sitemapURL, opts.UserAgent, 0, make(map[string]bool))
+		if err != nil {
+			fmt.Fprintln(out, au.Red("Error reading sitemap:"), au.Red(err))
+			exitCode = 1
+			continue
+		}
+		fmt.Fprintln(out, au.Green("Pages found:"), au.Blue(len(pages)))
+
+		results := requestSitemapPages(client, pages, opts, workers)
+		if len(results) < len(pages) {
+			fmt.Fprintln(out, au.Yellow("Interrupted after"), au.Yellow(len(results)), au.Yellow("pages"))
+			exitCode = exitInterrupted
+		}
+
+		printSitemapSummary(results)
+		if exitCode == exitInterrupted {
+			break
+		}
+	}
+	return exitCode
+}
+
+// requestSitemapPages hands the pages to a pool of workers and returns the
+// results in page order. After an interrupt no new pages are started, so
+// the results stop at the first page that was not
+func requestSitemapPages(client *http.Client, pages []string, opts requestOptions, workers int) []sitemapResult {
+	results := make([]sitemapResult, len(pages))
+	jobs := make(chan int)
+
+	// progress is redrawn in place, which only makes sense on a terminal
+	progress := isTerminal(out)
+	var mu sync.Mutex
+	var finished int
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = requestSitemapPage(client, pages[i], opts)
+				if progress {
+					mu.Lock()
+					finished++
+					fmt.Fprintf(out, "\r%s %d/%d", au.Magenta("Requested"), finished, len(pages))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	started := 0
+feed:
+	for i := range pages {
+		select {
+		case jobs <- i:
+			started++
+		case <-opts.context().Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if progress {
+		fmt.Fprintln(out)
+	}
+	return results[:started]
+}
+
+// requestSitemapPage runs the normal request pipeline on a state of its own,
+// so several pages can be in flight at once, with its narration muted
+func requestSitemapPage(client *http.Client, page string, opts requestOptions) sitemapResult {
+	st := requestState{out: io.Discard}
+	err := runRequest(client, page, opts, &st)
+
+	result := sitemapResult{URL: page, Err: err}
+	if len(st.responses) > 0 {
+		result.Status = st.responses[len(st.responses)-1].Response.StatusCode
+	}
+	if len(st.timeStats.CommonTimmings) > 0 {
+		result.TTFB = st.timeStats.CommonTimmings[len(st.timeStats.CommonTimmings)-1].TTFB
+	}
+	return result
+}
+
+func printSitemapSummary(results []sitemapResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tStatus\tTTFB")
+
+	statuses := make(map[int]int)
+	var ttfbs []time.Duration
+	var failed int
+	for _, result := range results {
+		if result.Err != nil && result.Status == 0 {
+			failed++
+			fmt.Fprintf(w, "%s\t%s\t%s\n", au.Cyan(result.URL), au.Red("error"), au.Red("-"))
+			continue
+		}
+		statuses[result.Status]++
+		ttfbs = append(ttfbs, result.TTFB)
+
+		status := au.Blue(result.Status)
+		if result.Status >= 400 {
+			status = au.Red(result.Status)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", au.Cyan(result.URL), status, au.Blue(formatDuration(result.TTFB)))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Pages"), au.Blue(fmt.Sprintf("%d (%d failed)", len(results), failed)))
+	if len(statuses) > 0 {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Status codes"), au.Blue(formatStatusCounts(statuses)))
+	}
+	if len(ttfbs) > 0 {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("TTFB"), au.Blue(formatDurationRange(ttfbs)))
+	}
+}
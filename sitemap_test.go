@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCrawlSitemapsWorkerPool(t *testing.T) {
+	const pages, workers = 8, 3
+
+	var inFlight, peak int32
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0"?><urlset>`)
+		for i := 0; i < pages; i++ {
+			// pretty-printed sitemaps wrap the URL in whitespace
+			if i%2 == 1 {
+				fmt.Fprintf(w, "\n  <url>\n    <loc>\n      %s/page%d\n    </loc>\n  </url>", srv.URL, i)
+				continue
+			}
+			fmt.Fprintf(w, "<url><loc>%s/page%d</loc></url>", srv.URL, i)
+		}
+		// skipped, neither a page nor a failure
+		fmt.Fprint(w, `<url><loc>unix:///var/run/docker.sock:/info</loc></url>`)
+		fmt.Fprint(w, `</urlset>`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		if r.URL.Path == "/page3" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	stdout := out
+	out = &buf
+	defer func() { out = stdout }()
+
+	opts := requestOptions{Method: http.MethodHead, Timeout: 5 * time.Second, MaxRedirects: 10}
+	if code := crawlSitemaps(srv.Client(), []string{srv.URL + "/sitemap.xml"}, opts, workers); code != 0 {
+		t.Fatalf("exit code %d\n%s", code, buf.String())
+	}
+
+	if got := atomic.LoadInt32(&peak); got > workers || got < 2 {
+		t.Errorf("%d pages in flight at most, want between 2 and %d", got, workers)
+	}
+	output := buf.String()
+	// the table keeps sitemap order whichever page finished first
+	last := -1
+	for i := 0; i < pages; i++ {
+		at := strings.Index(output, fmt.Sprintf("%s/page%d", srv.URL, i))
+		if at < last {
+			t.Errorf("page%d is listed out of order", i)
+		}
+		last = at
+	}
+	if !strings.Contains(output, "8 (0 failed)") || !strings.Contains(output, "404") {
+		t.Errorf("summary does not count the pages:\n%s", output)
+	}
+}
@@ -4,117 +4,638 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"mime"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/logrusorgru/aurora"
+	"golang.org/x/net/publicsuffix"
 )
 
-func performGetSize(client *http.Client, urlArg string) {
-	req, err := http.NewRequest("GET", urlArg, nil)
+func performGetSize(client *http.Client, urlArg string, opts sizeOptions) (resourceMap, error) {
+	req, err := http.NewRequestWithContext(opts.context(), "GET", urlArg, nil)
 	if err != nil {
-		fmt.Println(aurora.Green("Error creating request for size calculation:"), aurora.Blue(err))
-		return
+		fmt.Fprintln(out, au.Green("Error creating request for size calculation:"), au.Blue(err))
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	setAcceptEncoding(req)
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
 	}
+
 	resp, err := client.Do(req)
 	if err != nil {
-		fmt.Println(aurora.Red("Error sending request for size calculation:"), aurora.Red(err))
-		return
+		fmt.Fprintln(out, au.Red("Error sending request for size calculation:"), au.Red(err))
+		return nil, fmt.Errorf("sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	calculateSize(resp, client)
+	return calculateSize(resp, client, opts)
 }
 
-func calculateSize(resp *http.Response, client *http.Client) {
-	resourceMap := make(map[string][]resource)
+func calculateSize(resp *http.Response, client *http.Client, opts sizeOptions) (resourceMap, error) {
+	resources := make(resourceMap)
 	baseURL, err := url.Parse(resp.Request.URL.String())
 	if err != nil {
-		fmt.Println(aurora.Red("Error parsing base URL:"), aurora.Red(err))
-		return
+		fmt.Fprintln(out, au.Red("Error parsing base URL:"), au.Red(err))
+		return nil, fmt.Errorf("parsing base URL: %w", err)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	wire, truncated, err := readBody(resp.Body, opts.MaxBody)
 	if err != nil {
-		fmt.Println(aurora.Red("Error reading response body:"), aurora.Red(err))
-		return
+		fmt.Fprintln(out, au.Red("Error reading response body:"), au.Red(err))
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	// a truncated page is parsed as far as it got, which only works uncompressed
+	body := wire
+	if !truncated {
+		body, err = decodeBody(resp.Header.Get("Content-Encoding"), wire)
+		if err != nil {
+			fmt.Fprintln(out, au.Red("Error decoding response body:"), au.Red(err))
+			return nil, fmt.Errorf("decoding response body: %w", err)
+		}
 	}
 
 	// Add the page itself as a resource
 	pageResource := resource{
-		URL:  resp.Request.URL.String(),
-		Size: int64(len(body)),
-		Type: resp.Header.Get("Content-Type"),
+		URL:       resp.Request.URL.String(),
+		Size:      int64(len(body)),
+		WireSize:  int64(len(wire)),
+		Type:      resp.Header.Get("Content-Type"),
+		Hash:      hashBody(opts.Hash, body),
+		Truncated: truncated,
+	}
+	resources[pageResource.Type] = append(resources[pageResource.Type], pageResource)
+
+	// an image or API response has no resources to find, parsing it as HTML
+	// would only turn stray bytes into bogus links
+	if !isHTML(pageResource.Type, body) {
+		fmt.Fprintln(out, au.Yellow("Not an HTML page, reporting its own size only:"), au.Yellow(pageResource.Type))
+		return resources, nil
 	}
-	resourceMap[pageResource.Type] = append(resourceMap[pageResource.Type], pageResource)
 
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println(aurora.Red("Error parsing HTML:"), aurora.Red(err))
-		return
+		fmt.Fprintln(out, au.Red("Error parsing HTML:"), au.Red(err))
+		return nil, fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	// only resources are checked, the page itself was asked for explicitly
+	if opts.RespectRobots {
+		opts.robots = fetchRobots(opts.context(), client, baseURL, opts.UserAgent)
+		opts.robotsHost = baseURL.Host
 	}
 
+	selector := opts.Selector
+	if selector == "" {
+		selector = defaultResourceSelector
+	}
+
+	// fetch errors are printed from several goroutines at once
+	stdout := out
+	out = &lockedWriter{w: stdout}
+	c := newResourceCollector(client, resources, opts)
 	// Find links to other resources
-	doc.Find("link[href], script[src], img[src]").Each(func(i int, s *goquery.Selection) {
-		link, exists := s.Attr("href")
-		if !exists {
-			link, exists = s.Attr("src")
+	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+		for _, link := range resourceLinks(s) {
+			c.collect(link, baseURL, opts.CSSDepth)
+		}
+	})
+	collectIcons(doc, baseURL, c)
+	c.wait()
+	out = stdout
+	references := c.references
+
+	site := registrableDomain(baseURL.Hostname())
+	for _, typed := range resources {
+		for i := range typed {
+			typed[i].Count = references[typed[i].URL]
+			if typed[i].Count == 0 {
+				typed[i].Count = 1
+			}
+			// stylesheet references resolve against the stylesheet, but
+			// party is always judged against the page
+			if u, err := url.Parse(typed[i].URL); err == nil {
+				typed[i].ThirdParty = registrableDomain(u.Hostname()) != site
+			}
+		}
+	}
+
+	if err := opts.context().Err(); err != nil {
+		fmt.Fprintln(out, au.Yellow("Interrupted, sizes only cover the resources fetched so far"))
+		return resources, fmt.Errorf("calculating size: %w", err)
+	}
+	return resources, nil
+}
+
+// resourceCollector fetches the resources of a page concurrently. Its
+// semaphore is shared by every level of stylesheet recursion, so nested
+// fetches count against the same -concurrency limit as the page's own
+type resourceCollector struct {
+	client *http.Client
+	opts   sizeOptions
+	sem    chan struct{}
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	resources resourceMap
+	// reference counts by absolute URL, each resource is only fetched on its
+	// first reference which also stops @import cycles
+	references map[string]int
+	// when each URL was first referenced, so the listing keeps document
+	// order whichever fetch finished first
+	order map[string]int
+}
+
+func newResourceCollector(client *http.Client, resources resourceMap, opts sizeOptions) *resourceCollector {
+	limit := opts.Concurrency
+	if limit < 1 {
+		limit = 1
+	}
+	return &resourceCollector{
+		client:     client,
+		opts:       opts,
+		sem:        make(chan struct{}, limit),
+		resources:  resources,
+		references: make(map[string]int),
+		order:      make(map[string]int),
+	}
+}
+
+// collect fetches a resource in the background and, for stylesheets,
+// follows the url() and @import references it contains depth more levels
+func (c *resourceCollector) collect(link string, baseURL *url.URL, depth int) {
+	resourceURL, err := url.Parse(link)
+	if err != nil {
+		fmt.Fprintln(out, au.Red("Error parsing resource URL:"), au.Red(err))
+		return
+	}
+	resolved := baseURL.ResolveReference(resourceURL)
+	fullURL := resolved.String()
+
+	if !c.reference(fullURL) {
+		return
+	}
+	// after an interrupt the rest of the page is only counted, not fetched
+	if c.opts.context().Err() != nil {
+		return
+	}
+
+	if !robotsAllowed(c.opts, resolved) {
+		fmt.Fprintln(out, au.Yellow("Skipped (disallowed by robots.txt):"), au.Yellow(fullURL))
+		return
+	}
+
+	c.fetch(fullURL, baseURL, func(res *resource, body []byte) {
+		c.add(res.Type, *res)
+		if depth <= 0 || !strings.Contains(res.Type, "text/css") {
+			return
+		}
+		cssURL, err := url.Parse(res.URL)
+		if err != nil {
+			return
+		}
+		for _, ref := range parseCSSReferences(body) {
+			c.collect(ref, cssURL, depth-1)
+		}
+	})
+}
+
+// reference counts a reference to u and reports whether it was the first
+func (c *resourceCollector) reference(u string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.references[u]++
+	if c.references[u] > 1 {
+		return false
+	}
+	c.order[u] = len(c.order)
+	return true
+}
+
+// fetch runs fetchResource on its own goroutine once a semaphore slot is
+// free and hands a result to done. The slot is released before done runs,
+// so the fetches a stylesheet starts never wait on their parent
+func (c *resourceCollector) fetch(link string, baseURL *url.URL, done func(*resource, []byte)) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.sem <- struct{}{}
+		res, body := fetchResource(link, baseURL, c.client, c.opts)
+		<-c.sem
+		if res != nil {
+			done(res, body)
+		}
+	}()
+}
+
+func (c *resourceCollector) add(resType string, res resource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resources[resType] = append(c.resources[resType], res)
+}
+
+// wait blocks until every fetch, nested ones included, has finished and puts
+// the resources of each type back in the order they were referenced
+func (c *resourceCollector) wait() {
+	c.wg.Wait()
+	for _, typed := range c.resources {
+		sort.SliceStable(typed, func(i, j int) bool {
+			return c.order[typed[i].URL] < c.order[typed[j].URL]
+		})
+	}
+}
+
+// isHTML reports whether a page can hold resource references, sniffing the
+// body when the server sent no Content-Type
+func isHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// registrableDomain reduces a host to the domain a site owner registers, so
+// cdn.example.com and example.com compare equal. IPs and single label hosts
+// such as localhost have no public suffix and are kept whole
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	return domain
+}
+
+// defaultResourceSelector picks the elements size mode fetches, -select replaces it
+const defaultResourceSelector = "link[href], script[src], img[src], img[srcset], source[src], source[srcset], " +
+	"video[src], video[poster], audio[src], iframe[src], [style*='url(']"
+
+// resourceLinks returns every resource an element points at, including each
+// srcset candidate and url() references in an inline style
+func resourceLinks(s *goquery.Selection) []string {
+	var links []string
+	for _, attr := range []string{"href", "src", "poster"} {
+		if link, ok := s.Attr(attr); ok && link != "" {
+			links = append(links, link)
+		}
+	}
+	if srcset, ok := s.Attr("srcset"); ok {
+		links = append(links, parseSrcset(srcset)...)
+	}
+	if style, ok := s.Attr("style"); ok {
+		links = append(links, parseCSSReferences([]byte(style))...)
+	}
+	return links
+}
+
+// parseSrcset returns the URLs of a srcset list, "a.png 1x, b.png 2x" gives
+// a.png and b.png
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			urls = append(urls, fields[0])
 		}
+	}
+	return urls
+}
 
-		if exists {
-			resource := fetchResource(link, baseURL, client)
-			if resource != nil {
-				resourceMap[resource.Type] = append(resourceMap[resource.Type], *resource)
+var (
+	cssURLPattern    = regexp.MustCompile(`url\(\s*['"]?([^'")]+?)['"]?\s*\)`)
+	cssImportPattern = regexp.MustCompile(`@import\s+['"]([^'"]+)['"]`)
+)
+
+// parseCSSReferences returns the url() and @import targets of a stylesheet,
+// skipping inline data URIs
+func parseCSSReferences(css []byte) []string {
+	var refs []string
+	for _, pattern := range []*regexp.Regexp{cssImportPattern, cssURLPattern} {
+		for _, match := range pattern.FindAllSubmatch(css, -1) {
+			ref := strings.TrimSpace(string(match[1]))
+			if ref == "" || strings.HasPrefix(ref, "data:") {
+				continue
 			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// printResourceSizes lists the resources by type, or only the opts.Top
+// largest. Resources under opts.MinSize are left out of the listing but
+// still count towards every total
+func printResourceSizes(resMap resourceMap, opts sizeOptions) {
+	// heaviest type first, map order would shuffle the listing on every run
+	typeTotals := make(map[string]int64)
+	types := make([]string, 0, len(resMap))
+	for resType, resources := range resMap {
+		types = append(types, resType)
+		for _, resource := range resources {
+			typeTotals[resType] += resource.Size
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if typeTotals[types[i]] != typeTotals[types[j]] {
+			return typeTotals[types[i]] > typeTotals[types[j]]
 		}
+		return types[i] < types[j]
 	})
 
-	// Print resource sizes
-	var totalSize int64
-	for resType, resources := range resourceMap {
-		fmt.Println(aurora.Green("Type:"), aurora.Blue(resType))
-		var typeTotalSize int64
+	var totalSize, totalWireSize, thirdPartySize, thirdPartyWireSize int64
+	for _, resources := range resMap {
 		for _, resource := range resources {
-			fmt.Println(aurora.Green(resource.URL), aurora.Blue(resource.Size))
-			typeTotalSize += resource.Size
 			totalSize += resource.Size
+			totalWireSize += resource.WireSize
+			if resource.ThirdParty {
+				thirdPartySize += resource.Size
+				thirdPartyWireSize += resource.WireSize
+			}
+		}
+	}
+
+	if opts.Top > 0 {
+		printLargestResources(resMap, opts)
+	} else {
+		for _, resType := range types {
+			fmt.Fprintln(out, au.Green("Type:"), au.Blue(resType))
+			var hidden int
+			for _, resource := range resMap[resType] {
+				if resource.Size < opts.MinSize {
+					hidden++
+					continue
+				}
+				printResourceLine(resource)
+			}
+			if hidden > 0 {
+				fmt.Fprintln(out, au.Yellow(fmt.Sprintf("(%d under -min-size not shown)", hidden)))
+			}
+			fmt.Fprintln(out, au.Green("Total size for this type:"), au.Blue(typeTotals[resType]))
+		}
+	}
+	fmt.Fprintln(out, au.Green("Total size for all resources:"), au.Blue(totalSize))
+	fmt.Fprintln(out, au.Green("Total transferred for all resources:"), au.Blue(totalWireSize))
+	fmt.Fprintln(out, au.Green("First-party size:"), au.Blue(totalSize-thirdPartySize), au.Green("transferred:"), au.Blue(totalWireSize-thirdPartyWireSize))
+	fmt.Fprintln(out, au.Green("Third-party size:"), au.Blue(thirdPartySize), au.Green("transferred:"), au.Blue(thirdPartyWireSize))
+
+	if totalSize > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, au.Green("Breakdown by type"))
+		for _, resType := range types {
+			share := float64(typeTotals[resType]) / float64(totalSize)
+			fmt.Fprintln(out, au.Yellow(fmt.Sprintf("%-40s", resType)), au.Blue(shareBar(share, 20)), fmt.Sprintf("%5.1f%%", share*100))
+		}
+	}
+
+	printSlowestResources(resMap, opts)
+	printDuplicateResources(resMap)
+}
+
+func printResourceLine(resource resource) {
+	size := au.Blue(resource.Size)
+	if resource.Truncated {
+		size = au.Yellow(fmt.Sprintf("≥ %d (truncated)", resource.Size))
+	}
+	line := []any{au.Green(resource.URL), size}
+	if resource.Count > 1 {
+		line = append(line, au.Yellow(fmt.Sprintf("(x%d)", resource.Count)))
+	}
+	if resource.Timing != nil {
+		line = append(line, au.Magenta("TTFB "+formatDuration(resource.Timing.TTFB)))
+	}
+	fmt.Fprintln(out, line...)
+}
+
+// printSlowestResources lists the timed resources by TTFB, slowest first,
+// limited to opts.Top or ten
+func printSlowestResources(resMap resourceMap, opts sizeOptions) {
+	var timed []resource
+	for _, resources := range resMap {
+		for _, resource := range resources {
+			if resource.Timing != nil {
+				timed = append(timed, resource)
+			}
+		}
+	}
+	if len(timed) == 0 {
+		return
+	}
+	sort.Slice(timed, func(i, j int) bool {
+		if timed[i].Timing.TTFB != timed[j].Timing.TTFB {
+			return timed[i].Timing.TTFB > timed[j].Timing.TTFB
+		}
+		return timed[i].URL < timed[j].URL
+	})
+	limit := opts.Top
+	if limit == 0 {
+		limit = 10
+	}
+	if len(timed) > limit {
+		timed = timed[:limit]
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, au.Green(fmt.Sprintf("Slowest %d resources by TTFB", len(timed))))
+	for _, resource := range timed {
+		t := resource.Timing
+		fmt.Fprintf(out, "%-10s %s %s\n", au.Magenta(formatDuration(t.TTFB)), au.Green(resource.URL),
+			au.Blue(fmt.Sprintf("(DNS %s, TCP %s, TLS %s)", formatDNSDuration(*t), formatTCPDuration(*t), formatDuration(t.TLSHandshakeTime))))
+	}
+}
+
+// printLargestResources lists the opts.Top biggest resources of any type,
+// largest first
+func printLargestResources(resMap resourceMap, opts sizeOptions) {
+	type typedResource struct {
+		resType string
+		resource
+	}
+	var all []typedResource
+	for resType, resources := range resMap {
+		for _, resource := range resources {
+			if resource.Size >= opts.MinSize {
+				all = append(all, typedResource{resType, resource})
+			}
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Size != all[j].Size {
+			return all[i].Size > all[j].Size
+		}
+		return all[i].URL < all[j].URL
+	})
+	if len(all) > opts.Top {
+		all = all[:opts.Top]
+	}
+
+	fmt.Fprintln(out, au.Green(fmt.Sprintf("Largest %d resources", len(all))))
+	for _, r := range all {
+		fmt.Fprint(out, au.Yellow(fmt.Sprintf("%-12s", r.resType)), " ")
+		printResourceLine(r.resource)
+	}
+}
+
+// shareBar draws share (0 to 1) as a bar of width cells
+func shareBar(share float64, width int) string {
+	filled := int(math.Round(share * float64(width)))
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// printDuplicateResources lists resources served from different URLs with the
+// same body hash, it prints nothing unless -hash was given
+func printDuplicateResources(resMap resourceMap) {
+	byHash := make(map[string][]string)
+	var hashes []string
+	for _, resources := range resMap {
+		for _, resource := range resources {
+			if resource.Hash == "" {
+				continue
+			}
+			if _, seen := byHash[resource.Hash]; !seen {
+				hashes = append(hashes, resource.Hash)
+			}
+			byHash[resource.Hash] = append(byHash[resource.Hash], resource.URL)
+		}
+	}
+
+	for _, hash := range hashes {
+		urls := byHash[hash]
+		if len(urls) < 2 {
+			continue
+		}
+		fmt.Fprintln(out, au.Yellow("Identical content:"), au.Blue(hash))
+		for _, u := range urls {
+			fmt.Fprintln(out, "  ", au.Green(u))
 		}
-		fmt.Println(aurora.Green("Total size for this type:"), aurora.Blue(typeTotalSize))
 	}
-	fmt.Println(aurora.Green("Total size for all resources:"), aurora.Blue(totalSize))
 }
 
-func fetchResource(link string, baseURL *url.URL, client *http.Client) *resource {
+// fetchResource measures a resource, preferring the Content-Length of a HEAD
+// response and only downloading it when that is missing or unreliable.
+// Stylesheets are always downloaded since their body is scanned for references
+func fetchResource(link string, baseURL *url.URL, client *http.Client, opts sizeOptions) (*resource, []byte) {
 	resourceURL, err := url.Parse(link)
 	if err != nil {
-		fmt.Println(aurora.Red("Error parsing resource URL:"), aurora.Red(err))
-		return nil
+		fmt.Fprintln(out, au.Red("Error parsing resource URL:"), au.Red(err))
+		return nil, nil
 	}
 
 	fullURL := baseURL.ResolveReference(resourceURL)
-	req, err := http.NewRequest("GET", fullURL.String(), nil)
+	// hashing needs the body, so it always downloads
+	if !opts.Accurate && opts.Hash == "" {
+		if res := headResource(fullURL.String(), client, opts); res != nil {
+			logger.Debug("sized resource from HEAD", "url", res.URL, "size", res.Size)
+			return res, nil
+		}
+	}
+	logger.Debug("fetching resource", "url", fullURL.String())
+
+	req, err := http.NewRequestWithContext(opts.context(), "GET", fullURL.String(), nil)
 	if err != nil {
-		fmt.Println(aurora.Red("Error creating request for resource:"), aurora.Red(err))
-		return nil
+		fmt.Fprintln(out, au.Red("Error creating request for resource:"), au.Red(err))
+		return nil, nil
+	}
+	setAcceptEncoding(req)
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+
+	resp, timing, err := doResourceRequest(client, req, opts)
+	if err != nil {
+		fmt.Fprintln(out, au.Red("Error fetching resource:"), au.Red(err))
+		return nil, nil
 	}
+	defer resp.Body.Close()
+
+	wire, truncated, err := readBody(resp.Body, opts.MaxBody)
+	if err != nil {
+		fmt.Fprintln(out, au.Red("Error reading resource body:"), au.Red(err))
+		return nil, nil
+	}
+	logger.Debug("fetched resource", "url", fullURL.String(), "status", resp.StatusCode, "wire_size", len(wire), "truncated", truncated)
+
+	body := wire
+	if !truncated {
+		body, err = decodeBody(resp.Header.Get("Content-Encoding"), wire)
+		if err != nil {
+			fmt.Fprintln(out, au.Red("Error decoding resource body:"), au.Red(err))
+			body = wire
+		}
+	}
+
+	return &resource{
+		URL:       fullURL.String(),
+		Size:      int64(len(body)),
+		WireSize:  int64(len(wire)),
+		Type:      resp.Header.Get("Content-Type"),
+		Status:    resp.StatusCode,
+		Hash:      hashBody(opts.Hash, body),
+		Truncated: truncated,
+		Timing:    timing,
+	}, body
+}
+
+// doResourceRequest sends req, traced with the same hooks as the main request
+// when -resource-timing is on. The trace's narration of every phase is
+// dropped so the listing stays readable
+func doResourceRequest(client *http.Client, req *http.Request, opts sizeOptions) (*http.Response, *timmingsCommon, error) {
+	if !opts.Timing {
+		resp, err := client.Do(req)
+		return resp, nil, err
+	}
+
+	var timing *timmingsCommon
+	trace := createHTTPTrace(io.Discard, make(http.Header), nil, func(t timmingsCommon) { timing = &t })
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
 
 	resp, err := client.Do(req)
+	return resp, timing, err
+}
+
+// headResource sizes a resource from a HEAD response, returning nil when the
+// answer is not a 2xx (a 3xx or 404 carries the length of some other body),
+// omits Content-Length, or the length is of an encoded body
+func headResource(link string, client *http.Client, opts sizeOptions) *resource {
+	req, err := http.NewRequestWithContext(opts.context(), "HEAD", link, nil)
 	if err != nil {
-		fmt.Println(aurora.Red("Error fetching resource:"), aurora.Red(err))
 		return nil
 	}
-	defer resp.Body.Close()
+	setAcceptEncoding(req)
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	resp, timing, err := doResourceRequest(client, req, opts)
 	if err != nil {
-		fmt.Println(aurora.Red("Error reading resource body:"), aurora.Red(err))
+		return nil
+	}
+	resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode/100 != 2 || resp.ContentLength < 0 || strings.Contains(contentType, "text/css") {
+		return nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
 		return nil
 	}
 
 	return &resource{
-		URL:  fullURL.String(),
-		Size: int64(len(body)),
-		Type: resp.Header.Get("Content-Type"),
+		URL:      link,
+		Size:     resp.ContentLength,
+		WireSize: resp.ContentLength,
+		Type:     contentType,
+		Status:   resp.StatusCode,
+		Timing:   timing,
 	}
 }
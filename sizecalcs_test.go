@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResourceCollectorSharesLimit(t *testing.T) {
+	var inFlight, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		if r.URL.Path == "/style.css" {
+			w.Header().Set("Content-Type", "text/css")
+			for i := 0; i < 4; i++ {
+				fmt.Fprintf(w, "@font-face { src: url(/font%d.woff) }\n", i)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(make([]byte, 10))
+	}))
+	defer srv.Close()
+
+	base, _ := url.Parse(srv.URL + "/")
+	resources := make(resourceMap)
+	c := newResourceCollector(srv.Client(), resources, sizeOptions{Concurrency: 2, CSSDepth: 1, Accurate: true})
+	c.collect("style.css", base, 1)
+	for i := 0; i < 5; i++ {
+		c.collect(fmt.Sprintf("img%d.png", i), base, 1)
+	}
+	// a second reference is counted, not fetched again
+	c.collect("img0.png", base, 1)
+	c.wait()
+
+	if got := atomic.LoadInt32(&peak); got > 2 {
+		t.Errorf("%d requests in flight, the limit is 2", got)
+	}
+	if n := len(resources["text/css"]); n != 1 {
+		t.Errorf("%d stylesheets, want 1", n)
+	}
+	images := resources["image/png"]
+	if len(images) != 9 {
+		t.Fatalf("%d images and fonts, want 9", len(images))
+	}
+	// the page's images were referenced before the stylesheet's fonts
+	for i := 0; i < 5; i++ {
+		if want := fmt.Sprintf("%s/img%d.png", srv.URL, i); images[i].URL != want {
+			t.Errorf("resource %d is %s, want %s", i, images[i].URL, want)
+		}
+	}
+	if c.references[srv.URL+"/img0.png"] != 2 {
+		t.Errorf("img0.png counted %d times, want 2", c.references[srv.URL+"/img0.png"])
+	}
+}
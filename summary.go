@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// printSummaryLine prints the -q line "STATUS TTFB TOTAL SIZE URL" for one
+// target, padded so consecutive targets line up. info is nil when no
+// response arrived
+func printSummaryLine(urlArg string, info *responseInfo, t *timmings) {
+	if info == nil {
+		fmt.Fprintln(out, au.Red(fmt.Sprintf("%-3s %10s %10s %10s", "ERR", "-", "-", "-")), au.Cyan(displayURL(urlArg)))
+		return
+	}
+
+	var ttfb time.Duration
+	if len(t.CommonTimmings) > 0 {
+		ttfb = t.CommonTimmings[len(t.CommonTimmings)-1].TTFB
+	}
+
+	fmt.Fprintln(out,
+		colorizeStatus(info.Response.StatusCode),
+		au.Blue(fmt.Sprintf("%10s", formatDuration(ttfb))),
+		au.Blue(fmt.Sprintf("%10s", formatDuration(t.TotalRequestTime))),
+		au.Blue(fmt.Sprintf("%10d", info.ContentSize)),
+		au.Cyan(displayURL(info.URL)))
+}
+
+// printConnectionReuseStats reports how many requests of a run went out on a
+// kept-alive connection. Untraced transports such as HTTP/3 are left out
+func printConnectionReuseStats(timings []timmingsCommon) {
+	var requests, reused int
+	for _, t := range timings {
+		if t.PhasesUnavailable {
+			continue
+		}
+		requests++
+		if t.ConnectionReused {
+			reused++
+		}
+	}
+	if requests == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "%s %s\n", au.Green("Connections:"), au.Blue(fmt.Sprintf("%d requests, %d new connections, %d reused (%.0f%%)",
+		requests, requests-reused, reused, float64(reused)/float64(requests)*100)))
+}
+
+// colorizeStatus colors a padded status code by class
+func colorizeStatus(code int) aurora.Value {
+	return colorizeByStatus(code, fmt.Sprintf("%-3d", code))
+}
+
+// colorizeByStatus colors text by the class of code: 2xx green, 3xx cyan,
+// 4xx yellow and 5xx red
+func colorizeByStatus(code int, status string) aurora.Value {
+	switch {
+	case code >= 500:
+		return au.Red(status)
+	case code >= 400:
+		return au.Yellow(status)
+	case code >= 300:
+		return au.Cyan(status)
+	default:
+		return au.Green(status)
+	}
+}
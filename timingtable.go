@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+)
+
+// printTimingTable lays out every traced connection side by side and marks the
+// fastest value of each column. Every cell is colored so the escape codes have
+// the same width in a column and tabwriter keeps them aligned.
+func printTimingTable(t *timmings) {
+	columns := []struct {
+		name  string
+		value func(timmingsCommon) time.Duration
+	}{
+		{"DNS", func(c timmingsCommon) time.Duration { return c.DNSLookupTime }},
+		{"TCP", func(c timmingsCommon) time.Duration { return c.TCPConnTime }},
+		{"TLS", func(c timmingsCommon) time.Duration { return c.TLSHandshakeTime }},
+		{"TTFB", func(c timmingsCommon) time.Duration { return c.TTFB }},
+		{"Total", func(c timmingsCommon) time.Duration {
+			return c.DNSLookupTime + c.TCPConnTime + c.TLSHandshakeTime + c.TTFB
+		}},
+	}
+
+	fastest := make([]time.Duration, len(columns))
+	for i, column := range columns {
+		for j, conn := range t.CommonTimmings {
+			if v := column.value(conn); j == 0 || v < fastest[i] {
+				fastest[i] = v
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "#")
+	for _, column := range columns {
+		fmt.Fprintf(w, "\t%s", column.name)
+	}
+	fmt.Fprintln(w)
+
+	for j, conn := range t.CommonTimmings {
+		fmt.Fprintf(w, "%d", j+1)
+		for i, column := range columns {
+			v := column.value(conn)
+			if v == fastest[i] {
+				fmt.Fprintf(w, "\t%s", au.Green(formatDuration(v)))
+			} else {
+				fmt.Fprintf(w, "\t%s", au.Blue(formatDuration(v)))
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	fmt.Fprintln(out)
+}
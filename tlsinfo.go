@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+	"golang.org/x/crypto/ocsp"
+)
+
+// certificates expiring within this window are highlighted
+const certExpiryWarning = 30 * 24 * time.Hour
+
+func getTLSVersion(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("Unknown (0x%04x)", version)
+}
+
+// parseTLSVersion maps the -min-tls and -max-tls values to crypto/tls versions
+func parseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", s)
+}
+
+// isTLSVersionMismatch matches handshakes that failed because client and
+// server share no protocol version. The server ends those with a
+// protocol_version alert, which QUIC hands over as a tls.AlertError. Only
+// what crypto/tls refuses on our side, a -min-tls above -max-tls or a
+// server picking a version we turned off, is left to the error text
+func isTLSVersionMismatch(err error) bool {
+	var alert tls.AlertError
+	if errors.As(err, &alert) && alert == alertProtocolVersion {
+		return true
+	}
+	if alert, ok := remoteTLSAlert(err); ok {
+		return alert == alertProtocolVersion
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "no supported versions") ||
+		strings.Contains(msg, "server selected unsupported protocol version")
+}
+
+// describeCertificateError explains why certificate verification failed in
+// terms of the certificate, falling back to the error text for other reasons
+func describeCertificateError(err error) string {
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired && invalidErr.Cert != nil {
+		cert := invalidErr.Cert
+		if time.Now().Before(cert.NotBefore) {
+			return "certificate is not valid until " + cert.NotBefore.Format(time.DateOnly)
+		}
+		return "certificate expired on " + cert.NotAfter.Format(time.DateOnly)
+	}
+
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) && hostnameErr.Certificate != nil {
+		names := hostnameErr.Certificate.DNSNames
+		for _, ip := range hostnameErr.Certificate.IPAddresses {
+			names = append(names, ip.String())
+		}
+		if len(names) == 0 {
+			return fmt.Sprintf("hostname mismatch: cert has no subject alternative names, only the common name %q which is no longer matched", hostnameErr.Certificate.Subject.CommonName)
+		}
+		return fmt.Sprintf("hostname mismatch: %s is not covered, cert valid for %s", hostnameErr.Host, strings.Join(names, ", "))
+	}
+
+	var unknownAuthErr x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthErr) && unknownAuthErr.Cert != nil {
+		if unknownAuthErr.Cert.Issuer.String() == unknownAuthErr.Cert.Subject.String() {
+			return "certificate is self-signed by " + unknownAuthErr.Cert.Subject.String()
+		}
+		return "certificate issued by an unknown authority: " + unknownAuthErr.Cert.Issuer.String()
+	}
+
+	return err.Error()
+}
+
+// TLS alerts a server ends the handshake with when it turns down the
+// client certificate or the protocol version
+const (
+	alertHandshakeFailure    = tls.AlertError(40)
+	alertBadCertificate      = tls.AlertError(42)
+	alertProtocolVersion     = tls.AlertError(70)
+	alertCertificateRequired = tls.AlertError(116)
+)
+
+// remoteTLSAlert returns the alert the server ended the handshake with.
+// crypto/tls reports it as a "remote error" *net.OpError around its own
+// unexported alert type, whose text is the same as the AlertError's. A bare
+// AlertError is one we raised ourselves, so it does not count
+func remoteTLSAlert(err error) (tls.AlertError, bool) {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "remote error" || opErr.Err == nil {
+		return 0, false
+	}
+	for _, alert := range []tls.AlertError{alertHandshakeFailure, alertBadCertificate, alertProtocolVersion, alertCertificateRequired} {
+		if opErr.Err.Error() == alert.Error() {
+			return alert, true
+		}
+	}
+	return 0, false
+}
+
+// certificateRequestKey is the context key of the flag noteCertificateRequest sets
+type certificateRequestKey struct{}
+
+// withCertificateRequestFlag lets the handshakes made for requests with ctx
+// record that the server asked for a client certificate
+func withCertificateRequestFlag(ctx context.Context) context.Context {
+	return context.WithValue(ctx, certificateRequestKey{}, new(atomic.Bool))
+}
+
+// certificateRequested reports whether a handshake made for ctx was asked for
+// a client certificate
+func certificateRequested(ctx context.Context) bool {
+	requested, ok := ctx.Value(certificateRequestKey{}).(*atomic.Bool)
+	return ok && requested.Load()
+}
+
+// noteCertificateRequest is the GetClientCertificate of clients without -cert.
+// It records the request on the handshake's context and, like crypto/tls
+// without certificates, answers with none
+func noteCertificateRequest(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if requested, ok := info.Context().Value(certificateRequestKey{}).(*atomic.Bool); ok {
+		requested.Store(true)
+	}
+	return new(tls.Certificate), nil
+}
+
+// isTLSRecordHeaderError matches handshakes answered by something that does not
+// speak TLS, typically a plain HTTP server on the port
+func isTLSRecordHeaderError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	return errors.As(err, &recordErr)
+}
+
+// getTLSCipherSuite resolves every suite known to crypto/tls, including the insecure ones
+func getTLSCipherSuite(id uint16) string {
+	name := tls.CipherSuiteName(id)
+	if strings.HasPrefix(name, "0x") {
+		return fmt.Sprintf("Unknown (0x%04x)", id)
+	}
+	return name
+}
+
+func printTLSInfo(t timmingsCommon) {
+	// no handshake was traced, so there is nothing to report
+	if t.PhasesUnavailable || t.ConnectionReused {
+		return
+	}
+
+	// what the handshake agreed on, which can differ from the response protocol
+	alpn := t.ALPNProtocol
+	if alpn == "" {
+		alpn = "none"
+	}
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("ALPN"), au.Blue(alpn))
+
+	if t.TLSVersion == "" {
+		return
+	}
+
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("TLS version"), au.Blue(t.TLSVersion))
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("Cipher suite"), au.Blue(t.TLSCipherSuite))
+	printOCSPInfo(t)
+	printCertificateChain(t)
+}
+
+// parseStapledOCSP reads the certificate status out of a stapled OCSP
+// response, checking its signature against the issuer when the chain has one
+func parseStapledOCSP(state tls.ConnectionState) (string, time.Time) {
+	var issuer *x509.Certificate
+	if len(state.PeerCertificates) > 1 {
+		issuer = state.PeerCertificates[1]
+	}
+
+	resp, err := ocsp.ParseResponse(state.OCSPResponse, issuer)
+	if err != nil {
+		return "unparseable: " + err.Error(), time.Time{}
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return "good", resp.NextUpdate
+	case ocsp.Revoked:
+		return "revoked at " + resp.RevokedAt.Format(time.RFC3339), resp.NextUpdate
+	}
+	return "unknown", resp.NextUpdate
+}
+
+func printOCSPInfo(t timmingsCommon) {
+	if !t.OCSPStapled {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("OCSP stapling"), au.Yellow("not stapled"))
+		return
+	}
+
+	status := au.Blue(t.OCSPStatus)
+	if t.OCSPStatus != "good" {
+		status = au.Red(t.OCSPStatus)
+	}
+	fmt.Fprintf(out, "%20s %s\n", au.Yellow("OCSP stapling"), status)
+	if !t.OCSPNextUpdate.IsZero() {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("OCSP next update"), au.Blue(t.OCSPNextUpdate.Format(time.RFC3339)))
+	}
+}
+
+func printCertificateChain(t timmingsCommon) {
+	if len(t.TLSCertSubjects) == 0 {
+		return
+	}
+
+	fmt.Fprintln(out, au.Green("Certificate chain:"))
+	for i, subject := range t.TLSCertSubjects {
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow(fmt.Sprintf("[%d] Subject", i)), au.Blue(subject))
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Issuer"), au.Blue(t.TLSCertIssuers[i]))
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Expires"), colorizeExpiry(t.TLSCertExpiry[i]))
+	}
+}
+
+func colorizeExpiry(notAfter time.Time) aurora.Value {
+	expiry := notAfter.Format(time.RFC3339)
+	remaining := time.Until(notAfter)
+
+	switch {
+	case remaining <= 0:
+		return au.Red(expiry + " (expired)")
+	case remaining < certExpiryWarning:
+		return au.Yellow(fmt.Sprintf("%s (expires in %d days)", expiry, int(remaining.Hours()/24)))
+	default:
+		return au.Blue(expiry)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert is a key pair for the TLS test servers and clients
+type testCert struct {
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+func (c testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.Cert.Raw}, PrivateKey: c.Key, Leaf: c.Cert}
+}
+
+// newTestCert issues a certificate from template, signed by parent or
+// self-signed when parent is nil
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.Cert, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCert{Cert: cert, Key: key}
+}
+
+func newTestCA(t *testing.T, name string) testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}, nil)
+}
+
+// newTestLeaf issues a server certificate for 127.0.0.1 and localhost
+func newTestLeaf(t *testing.T, ca *testCert, modify func(*x509.Certificate)) testCert {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "headview test"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if modify != nil {
+		modify(template)
+	}
+	return newTestCert(t, template, ca)
+}
+
+func TestGetTLSCipherSuite(t *testing.T) {
+	// every suite crypto/tls knows has a name, the insecure ones included
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			if got := getTLSCipherSuite(suite.ID); strings.HasPrefix(got, "Unknown") {
+				t.Errorf("getTLSCipherSuite(0x%04x) = %q, want %q", suite.ID, got, suite.Name)
+			}
+		}
+	}
+	if got := getTLSCipherSuite(0xfefe); got != "Unknown (0xfefe)" {
+		t.Errorf("getTLSCipherSuite(0xfefe) = %q, want \"Unknown (0xfefe)\"", got)
+	}
+}
+
+func TestPrintTLSInfoCipherSuite(t *testing.T) {
+	const suite = tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{suite}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	stdout := out
+	out = io.Discard
+	defer func() { out = stdout }()
+	client := createHTTPClient(clientOptions{Timeout: 5 * time.Second, Insecure: true, Network: "tcp"})
+	timeStats, responses = timmings{}, nil
+	performGetRequest(client, srv.URL, requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10})
+	if len(timeStats.CommonTimmings) == 0 {
+		t.Fatal("no connection was traced")
+	}
+	conn := timeStats.CommonTimmings[0]
+	if conn.TLSCipherSuite != tls.CipherSuiteName(suite) || conn.TLSVersion != "TLS 1.2" {
+		t.Errorf("traced %q over %q", conn.TLSCipherSuite, conn.TLSVersion)
+	}
+
+	var buf bytes.Buffer
+	out = &buf
+	printTLSInfo(conn)
+	for _, want := range []string{"Cipher suite", tls.CipherSuiteName(suite), "TLS version", "TLS 1.2"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+// newTestTLSServer serves cert, its handshake errors are expected and kept quiet
+func newTestTLSServer(t *testing.T, cert testCert) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert.tlsCertificate()}}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDescribeCertificateError(t *testing.T) {
+	ca := newTestCA(t, "headview test CA")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	other := newTestCA(t, "elsewhere CA")
+	// certificates carry UTC times, so the expected dates are in UTC too
+	expiredOn := time.Now().UTC().Add(-48 * time.Hour)
+	validFrom := time.Now().UTC().Add(48 * time.Hour)
+
+	tests := []struct {
+		name string
+		cert testCert
+		want string
+	}{
+		{"expired", newTestLeaf(t, &ca, func(c *x509.Certificate) {
+			c.NotBefore, c.NotAfter = expiredOn.Add(-time.Hour), expiredOn
+		}), "certificate expired on " + expiredOn.Format(time.DateOnly)},
+		{"not yet valid", newTestLeaf(t, &ca, func(c *x509.Certificate) {
+			c.NotBefore, c.NotAfter = validFrom, validFrom.Add(48*time.Hour)
+		}), "certificate is not valid until " + validFrom.Format(time.DateOnly)},
+		{"wrong host", newTestLeaf(t, &ca, func(c *x509.Certificate) {
+			c.DNSNames, c.IPAddresses = []string{"example.com"}, nil
+		}), "hostname mismatch: 127.0.0.1 is not covered, cert valid for example.com"},
+		{"common name only", newTestLeaf(t, &ca, func(c *x509.Certificate) {
+			c.DNSNames, c.IPAddresses = nil, nil
+		}), `hostname mismatch: cert has no subject alternative names, only the common name "headview test"`},
+		{"self-signed", newTestLeaf(t, nil, nil), "certificate is self-signed by CN=headview test"},
+		{"unknown authority", newTestLeaf(t, &other, nil), "certificate issued by an unknown authority: CN=elsewhere CA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestTLSServer(t, tt.cert)
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+			resp, err := client.Get(srv.URL)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatal("request succeeded, want a verification error")
+			}
+			if !isCertificateError(err) {
+				t.Fatalf("isCertificateError(%v) = false", err)
+			}
+			if got := describeCertificateError(err); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("describeCertificateError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelfSignedCertificateOutput(t *testing.T) {
+	srv := newTestTLSServer(t, newTestLeaf(t, nil, nil))
+	opts := requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10}
+
+	var buf bytes.Buffer
+	stdout := out
+	out = &buf
+	defer func() { out = stdout }()
+	client := createHTTPClient(clientOptions{Timeout: 5 * time.Second, Network: "tcp"})
+	timeStats, responses = timmings{}, nil
+	err := performGetRequest(client, srv.URL, opts)
+	if err == nil || !isCertificateError(err) {
+		t.Fatalf("got %v, want a certificate error", err)
+	}
+	for _, want := range []string{"certificate is self-signed by CN=headview test", "Use -insecure"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, buf.String())
+		}
+	}
+
+	// -insecure gets past the same certificate
+	out = io.Discard
+	client = createHTTPClient(clientOptions{Timeout: 5 * time.Second, Insecure: true, Network: "tcp"})
+	if err := performGetRequest(client, srv.URL, opts); err != nil {
+		t.Errorf("request with -insecure failed: %v", err)
+	}
+}
+
+func TestTLSVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		server       *tls.Config
+		clientMin    uint16
+		clientMax    uint16
+		wantMismatch bool
+	}{
+		{"server needs 1.3", &tls.Config{MinVersion: tls.VersionTLS13}, 0, tls.VersionTLS12, true},
+		{"server stops at 1.2", &tls.Config{MaxVersion: tls.VersionTLS12}, tls.VersionTLS13, 0, true},
+		{"shared 1.2", &tls.Config{MaxVersion: tls.VersionTLS12}, tls.VersionTLS12, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			srv.TLS = tt.server
+			srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+			srv.StartTLS()
+			defer srv.Close()
+
+			stdout := out
+			out = io.Discard
+			defer func() { out = stdout }()
+			client := createHTTPClient(clientOptions{Timeout: 5 * time.Second, Insecure: true, Network: "tcp",
+				MinTLSVersion: tt.clientMin, MaxTLSVersion: tt.clientMax})
+			timeStats, responses = timmings{}, nil
+			err := performGetRequest(client, srv.URL, requestOptions{Method: http.MethodGet, Timeout: 5 * time.Second, MaxRedirects: 10})
+			if got := err != nil && isTLSVersionMismatch(err); got != tt.wantMismatch {
+				t.Errorf("got %v, want a version mismatch %v", err, tt.wantMismatch)
+			}
+		})
+	}
+}
+
+func TestIsTLSVersionMismatchErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"remote protocol_version", &net.OpError{Op: "remote error", Err: alertProtocolVersion}, true},
+		{"protocol_version over QUIC", alertProtocolVersion, true},
+		{"remote handshake failure", &net.OpError{Op: "remote error", Err: alertHandshakeFailure}, false},
+		{"nothing to offer", errors.New("tls: no supported versions satisfy MinVersion and MaxVersion"), true},
+		{"server picked a version we turned off", errors.New("tls: server selected unsupported protocol version 303"), true},
+		{"other", io.ErrUnexpectedEOF, false},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("Get %q: %w", "https://example.com", tt.err)
+		if got := isTLSVersionMismatch(err); got != tt.want {
+			t.Errorf("%s: isTLSVersionMismatch(%v) = %v, want %v", tt.name, err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// unixSockets maps the placeholder host a unix socket target is requested
+// under to the socket the dialer connects to instead
+var unixSockets = map[string]string{}
+
+// unixSocketURL rewrites http+unix:///var/run/app.sock:/health, or the same
+// with unix://, to a plain http URL on a placeholder host. The socket path
+// ends at the first colon, a missing request path means /
+func unixSocketURL(s string) (string, bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(s, "http+unix://"):
+		rest = strings.TrimPrefix(s, "http+unix://")
+	case strings.HasPrefix(s, "unix://"):
+		rest = strings.TrimPrefix(s, "unix://")
+	default:
+		return s, false
+	}
+
+	socket, path, _ := strings.Cut(rest, ":")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return "http://" + unixSocketHost(socket) + path, true
+}
+
+// unixSocketHost hands out a placeholder host per socket. They sit under
+// .localhost so nothing would ever resolve them if one leaked to DNS
+func unixSocketHost(socket string) string {
+	for host, path := range unixSockets {
+		if path == socket {
+			return host
+		}
+	}
+	host := "unix.localhost"
+	if len(unixSockets) > 0 {
+		host = fmt.Sprintf("unix%d.localhost", len(unixSockets)+1)
+	}
+	unixSockets[host] = socket
+	return host
+}
+
+// unixSocketPath returns the socket behind a host:port the transport dials
+func unixSocketPath(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	socket, ok := unixSockets[host]
+	return socket, ok
+}
+
+// unixDisplayURL gives a placeholder URL back its http+unix:// form
+func unixDisplayURL(u *url.URL) (string, bool) {
+	socket, ok := unixSockets[u.Hostname()]
+	if !ok {
+		return "", false
+	}
+	return "http+unix://" + socket + ":" + u.RequestURI(), true
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// userAgentPresets are the -ua-preset choices, headview is the default
+var userAgentPresets = map[string]string{
+	"headview":  "headview/" + appVersion,
+	"chrome":    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36",
+	"firefox":   "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:117.0) Gecko/20100101 Firefox/117.0",
+	"safari":    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Safari/605.1.15",
+	"googlebot": "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+	"curl":      "curl/8.2.1",
+}
+
+// randomUserAgents is the pool -random-ua draws from, current desktop and
+// mobile browsers only since bots and tools are what it is meant to hide
+var randomUserAgents = []string{
+	userAgentPresets["chrome"],
+	userAgentPresets["firefox"],
+	userAgentPresets["safari"],
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36 Edg/116.0.1938.69",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/117.0",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (iPhone; CPU iPhone OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+	"Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Mobile Safari/537.36",
+}
+
+func randomUserAgent() string {
+	return randomUserAgents[rand.Intn(len(randomUserAgents))]
+}
+
+// userAgentForPreset looks up a preset, listing the valid names when it is unknown
+func userAgentForPreset(preset string) (string, error) {
+	if ua, ok := userAgentPresets[strings.ToLower(preset)]; ok {
+		return ua, nil
+	}
+
+	names := make([]string, 0, len(userAgentPresets))
+	for name := range userAgentPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown user agent preset %q, available: %s", preset, strings.Join(names, ", "))
+}
@@ -1,7 +1,24 @@
 package main
 
-import "time"
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
 
+	"github.com/logrusorgru/aurora"
+	"github.com/temoto/robotstxt"
+	"golang.org/x/time/rate"
+)
+
+// timmings holds one target's stats. Over a redirect chain the request
+// sending and server processing times are summed across hops, content
+// transfer is the final body only, and the total runs from the first hop's
+// request to the end of the final body
 type timmings struct {
 	CommonTimmings       []timmingsCommon
 	RequestSendingTime   time.Duration
@@ -14,14 +31,223 @@ type timmingsCommon struct {
 	DNSLookupTime    time.Duration
 	TCPConnTime      time.Duration
 	TLSHandshakeTime time.Duration
-	TTFB             time.Duration
+	// from the request being sent on a ready connection to the first response byte
+	TTFB time.Duration
+	// from the request being written to the first response byte, measured
+	// the same way on new and reused connections
+	WaitingForServerTime time.Duration
+	DNSSkipped           bool
+	// why a fresh connection went without a lookup: an IP literal target or a -resolve pin
+	DNSSkipReason string
+	// host being resolved and whether the lookup shared another in flight
+	DNSHost      string
+	DNSCoalesced bool
+	// from asking the pool for a connection to the lookup starting
+	DNSQueueTime     time.Duration
+	ConnectionReused bool
+	// dialed a http+unix:// target, so there was no DNS or TCP phase
+	UnixSocket bool
+	Protocol   string
+	// exact version of the response, Protocol is the text the server sent
+	ProtoMajor int
+	ProtoMinor int
+	// set when the transport gives no per phase trace, as with HTTP/3
+	PhasesUnavailable   bool
+	RemoteAddr          string
+	ResolvedIPs         []string
+	AdvertisedProtocols []string
+	TLSVersion          string
+	TLSCipherSuite      string
+	ALPNProtocol        string
+	OCSPStapled         bool
+	OCSPStatus          string
+	OCSPNextUpdate      time.Time
+	TLSCertSubjects     []string
+	TLSCertIssuers      []string
+	TLSCertExpiry       []time.Time
 }
 
 type resource struct {
-	URL  string
-	Size int64
-	Type string
+	URL      string
+	Size     int64
+	WireSize int64
+	Type     string
+	Status   int
+	// number of times the page referenced this URL, it is only fetched once
+	Count int
+	Hash  string
+	// served from a different registrable domain than the page
+	ThirdParty bool
+	// the body was cut off at -max-body, sizes are lower bounds
+	Truncated bool
+	// connection phases of the fetch, only set with -resource-timing
+	Timing *timmingsCommon
+}
+
+type resourceMap map[string][]resource
+
+type responseInfo struct {
+	URL         string
+	Response    *http.Response
+	StatusCode  int
+	StatusText  string
+	ContentSize int64
+	WireSize    int64
+	// status line and headers as HTTP/1.1 would serialize them
+	HeaderSize int64
+	// the hop led on through a <meta http-equiv="refresh"> instead of a 3xx
+	MetaRefresh bool
+	Started     time.Time
+	Attempts    int
+	BodyHash    string
+	// as written to the connection, including transport added ones
+	RequestHeaders http.Header
+	// the body was cut off at -max-body, sizes are lower bounds
+	Truncated bool
+}
+
+type requestOptions struct {
+	Method       string
+	Headers      http.Header
+	PrintHeaders bool
+	// lowercase names or globs from -header-filter, empty prints every header
+	HeaderFilter []string
+	// print the headers that went out on the wire for every hop
+	PrintRequestHeaders bool
+	FollowMetaRefresh   bool
+	// replace UserAgent with a pick from randomUserAgents on every request
+	RandomUserAgent bool
+	NoRedirect      bool
+	MaxRedirects    int
+	Timeout         time.Duration
+	AuthUser        string
+	AuthPassword    string
+	BearerToken     string
+	Host            string
+	UserAgent       string
+	// request body from -data, nil sends none
+	Body        []byte
+	ContentType string
+	Retries     int
+	RetryStatus bool
+	Cookies     []*http.Cookie
+	// shared by every request of the run, nil when -rate is not set
+	Limiter *rate.Limiter
+	// the client's -resolve pins, used to explain lookups that never ran
+	Resolve  map[string]string
+	SaveBody string
+	// cancelled on SIGINT or SIGTERM, nil behaves as context.Background
+	ctx  context.Context
+	Hash string
+	// bytes of the body to read, 0 reads all of it
+	MaxBody    int64
+	originHost string
+}
+
+type runOptions struct {
+	Size        bool
+	SizeOptions sizeOptions
+	// one of the format* constants
+	Format     string
+	Quiet      bool
+	StatusLine bool
+	Repeat     int
+	// targets requested at once, 1 runs them in order
+	ConcurrentURLs int
+	Waterfall      bool
+	Security       bool
+	Cache          bool
+	// guess the CDN and origin stack from identifying headers
+	Fingerprint bool
+	// the -range that was sent, nil without one
+	Range      *byteRange
+	TraceDNS   bool
+	OpenHAR    bool
+	FullTiming bool
+	Thresholds timingThresholds
+
+	FailOnStatus []string
+	MaxTTFB      time.Duration
+
+	ExpectStatus  []string
+	ExpectHeaders []headerExpectation
+}
+
+// timingThresholds colors durations: under Good is green, over Bad is red and
+// anything between is yellow
+type timingThresholds struct {
+	Good time.Duration
+	Bad  time.Duration
+}
+
+type sizeOptions struct {
+	CSSDepth int
+	// download every resource instead of trusting Content-Length from HEAD
+	Accurate bool
+	// digest algorithm for per-resource hashes, implies Accurate
+	Hash string
+	// goquery selector for resource elements, empty uses defaultResourceSelector
+	Selector      string
+	UserAgent     string
+	RespectRobots bool
+	// bytes of each body to read, 0 reads all of it
+	MaxBody int64
+	// listing filters, the totals always cover every resource
+	MinSize int64
+	Top     int
+	// trace every resource fetch like the main request
+	Timing bool
+	// resources fetched at once, stylesheet references included
+	Concurrency int
+	// cancelled on SIGINT or SIGTERM, nil behaves as context.Background
+	ctx context.Context
+	// robots.txt of the page host, loaded by calculateSize with -respect-robots
+	robots     *robotstxt.RobotsData
+	robotsHost string
+}
+
+type clientOptions struct {
+	Timeout      time.Duration
+	Insecure     bool
+	Network      string
+	Proxy        *url.URL
+	Resolve      map[string]string
+	Certificates []tls.Certificate
+	ServerName   string
+	// zero leaves the crypto/tls defaults
+	MinTLSVersion uint16
+	MaxTLSVersion uint16
+	HTTP3         bool
+	ForceHTTP1    bool
+	NoKeepAlive   bool
 }
 
 var appVersion = "0.1.17"
 var timeStats timmings
+var responses []responseInfo
+
+// requestState is what one run of the request pipeline collects, the
+// package level timeStats and responses above hold the last one. out is
+// where the run narrates its progress
+type requestState struct {
+	timeStats timmings
+	responses []responseInfo
+	out       io.Writer
+	// the User-Agent the run sent, set before the first attempt so a failed
+	// run has one too
+	userAgent string
+}
+
+// out receives all human readable output, json mode moves it to stderr
+var out io.Writer = os.Stdout
+
+// reportOut receives JSON, HAR and CSV reports, -o points both at a file
+var reportOut io.Writer = os.Stdout
+
+// au colorizes output, main disables it for -no-color or when out is not a terminal
+var au = aurora.NewAurora(true)
+
+var colorEnabled = true
+
+// logger writes -verbose diagnostics to stderr, it discards them otherwise
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// watchSample is the outcome of one -watch iteration for one target
+type watchSample struct {
+	Status int
+	TTFB   time.Duration
+	Failed bool
+}
+
+// watchTargets re-runs every target each interval on the same client, so
+// keep-alive connections carry over, until SIGINT ends the session. The
+// interrupt also cancels a request in flight rather than waiting it out
+func watchTargets(client *http.Client, targets []string, opts requestOptions, run runOptions, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	samples := make(map[string][]watchSample)
+	for iteration := 1; ; iteration++ {
+		if isTerminal(out) {
+			fmt.Fprint(out, clearScreen)
+		}
+		fmt.Fprintln(out, au.Green("Watch iteration"), au.Blue(iteration), au.Green("at"), au.Blue(time.Now().Format(time.TimeOnly)), au.Green("(Ctrl-C to stop)"))
+
+		for _, urlArg := range targets {
+			err := runTarget(client, urlArg, opts, run, nil)
+			if opts.context().Err() != nil {
+				// a cancelled request says nothing about the target
+				break
+			}
+			samples[urlArg] = append(samples[urlArg], currentWatchSample(err))
+		}
+
+		select {
+		case <-opts.context().Done():
+			printWatchSummary(targets, samples)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// currentWatchSample reads the final status and TTFB left behind by runTarget
+func currentWatchSample(err error) watchSample {
+	sample := watchSample{Failed: err != nil}
+	if len(responses) > 0 {
+		sample.Status = responses[len(responses)-1].Response.StatusCode
+	}
+	if len(timeStats.CommonTimmings) > 0 {
+		sample.TTFB = timeStats.CommonTimmings[len(timeStats.CommonTimmings)-1].TTFB
+	}
+	return sample
+}
+
+func printWatchSummary(targets []string, samples map[string][]watchSample) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, au.Green("Watch summary"))
+
+	for _, urlArg := range targets {
+		runs := samples[urlArg]
+		if len(runs) == 0 {
+			continue
+		}
+
+		statuses := make(map[int]int)
+		var failed int
+		var ttfbs []time.Duration
+		for _, sample := range runs {
+			if sample.Failed {
+				failed++
+			}
+			if sample.Status != 0 {
+				statuses[sample.Status]++
+				ttfbs = append(ttfbs, sample.TTFB)
+			}
+		}
+
+		fmt.Fprintln(out, au.Magenta("URL:"), au.Cyan(displayURL(urlArg)))
+		fmt.Fprintf(out, "%20s %s\n", au.Yellow("Iterations"), au.Blue(fmt.Sprintf("%d (%d failed)", len(runs), failed)))
+		if len(statuses) > 0 {
+			fmt.Fprintf(out, "%20s %s\n", au.Yellow("Status codes"), au.Blue(formatStatusCounts(statuses)))
+		}
+		if len(ttfbs) > 0 {
+			fmt.Fprintf(out, "%20s %s\n", au.Yellow("TTFB"), au.Blue(formatDurationRange(ttfbs)))
+		}
+	}
+}
+
+// formatDurationRange renders "min a, avg b, max c", sorting durations in place
+func formatDurationRange(durations []time.Duration) string {
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	return fmt.Sprintf("min %s, avg %s, max %s",
+		formatDuration(durations[0]),
+		formatDuration(sum/time.Duration(len(durations))),
+		formatDuration(durations[len(durations)-1]))
+}
+
+// formatStatusCounts renders counts as "200 x5, 503 x1" in status order
+func formatStatusCounts(statuses map[int]int) string {
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("%d x%d", code, statuses[code])
+	}
+	return strings.Join(parts, ", ")
+}
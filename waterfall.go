@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+	"golang.org/x/term"
+)
+
+const (
+	waterfallLabelWidth    = 20
+	waterfallDurationWidth = 12
+	defaultTerminalWidth   = 80
+)
+
+// printWaterfall draws each phase as a bar starting where the previous one ended
+func printWaterfall(timing timmingsCommon, transfer time.Duration) {
+	phases := []struct {
+		name     string
+		duration time.Duration
+		color    func(interface{}) aurora.Value
+	}{
+		{"DNS lookup", timing.DNSLookupTime, au.Cyan},
+		{"TCP connection", timing.TCPConnTime, au.Yellow},
+		{"TLS handshake", timing.TLSHandshakeTime, au.Magenta},
+		{"Wait", timing.TTFB, au.Green},
+		{"Content transfer", transfer, au.Blue},
+	}
+
+	var total time.Duration
+	for _, phase := range phases {
+		total += phase.duration
+	}
+	if total <= 0 {
+		return
+	}
+
+	barWidth := terminalWidth(out) - waterfallLabelWidth - waterfallDurationWidth - 2
+	if barWidth < 10 {
+		barWidth = 10
+	}
+
+	fmt.Fprintln(out, au.Green("Waterfall"))
+	var offset time.Duration
+	for _, phase := range phases {
+		start := int(float64(offset) / float64(total) * float64(barWidth))
+		length := int(float64(phase.duration) / float64(total) * float64(barWidth))
+		if phase.duration > 0 && length == 0 {
+			length = 1
+		}
+		if start+length > barWidth {
+			start = barWidth - length
+		}
+
+		bar := strings.Repeat(" ", start) + phase.color(strings.Repeat("█", length)).String() + strings.Repeat(" ", barWidth-start-length)
+		fmt.Fprintf(out, "%20s %s %s\n", au.Yellow(phase.name), bar, formatDuration(phase.duration))
+		offset += phase.duration
+	}
+	fmt.Fprintln(out)
+}
+
+// terminalWidth is the width of w when it is a terminal, output redirected
+// to a file or pipe gets the fixed default so it does not depend on where
+// the command happened to run
+func terminalWidth(w io.Writer) int {
+	if !isTerminal(w) {
+		return defaultTerminalWidth
+	}
+	width, _, err := term.GetSize(int(w.(*os.File).Fd()))
+	if err != nil || width <= 0 {
+		return defaultTerminalWidth
+	}
+	return width
+}